@@ -2,6 +2,7 @@ package tg
 
 import (
 	"context"
+	"io"
 )
 
 // AffiliateInfo Contains information about the affiliate that received a commission via this transaction.
@@ -1134,6 +1135,9 @@ func (impl *ChatPhoto) Download(ctx context.Context, path string) error {
 func (impl *ChatPhoto) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.BigFileId, dirAndPattern...)
 }
+func (impl *ChatPhoto) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.BigFileId)
+}
 
 // ChatShared This object contains information about a chat that was shared with the bot using a KeyboardButtonRequestChat button.
 type ChatShared struct {
@@ -1347,6 +1351,9 @@ func (impl *File) Download(ctx context.Context, path string) error {
 func (impl *File) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.FileId, dirAndPattern...)
 }
+func (impl *File) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.FileId)
+}
 
 // ForceReply Upon receiving a message with this object, Telegram clients will display a reply interface to the user (act as if the user has selected the bot's message and tapped 'Reply').
 // This can be extremely useful if you want to create user-friendly step-by-step interfaces without having to sacrifice privacy mode.
@@ -2261,6 +2268,9 @@ func (impl *InlineQueryResultCachedAudio) Download(ctx context.Context, path str
 func (impl *InlineQueryResultCachedAudio) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.AudioFileId, dirAndPattern...)
 }
+func (impl *InlineQueryResultCachedAudio) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.AudioFileId)
+}
 
 // InlineQueryResultCachedDocument Represents a link to a file stored on the Telegram servers.
 // By default, this file will be sent by the user with an optional caption.
@@ -2294,6 +2304,9 @@ func (impl *InlineQueryResultCachedDocument) Download(ctx context.Context, path
 func (impl *InlineQueryResultCachedDocument) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.DocumentFileId, dirAndPattern...)
 }
+func (impl *InlineQueryResultCachedDocument) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.DocumentFileId)
+}
 
 // InlineQueryResultCachedGif Represents a link to an animated GIF file stored on the Telegram servers.
 // By default, this animated GIF file will be sent by the user with an optional caption.
@@ -2327,6 +2340,9 @@ func (impl *InlineQueryResultCachedGif) Download(ctx context.Context, path strin
 func (impl *InlineQueryResultCachedGif) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.GifFileId, dirAndPattern...)
 }
+func (impl *InlineQueryResultCachedGif) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.GifFileId)
+}
 
 // InlineQueryResultCachedMpeg4Gif Represents a link to a video animation (H.264/MPEG-4 AVC video without sound) stored on the Telegram servers.
 // By default, this animated MPEG-4 file will be sent by the user with an optional caption.
@@ -2360,6 +2376,9 @@ func (impl *InlineQueryResultCachedMpeg4Gif) Download(ctx context.Context, path
 func (impl *InlineQueryResultCachedMpeg4Gif) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.Mpeg4FileId, dirAndPattern...)
 }
+func (impl *InlineQueryResultCachedMpeg4Gif) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.Mpeg4FileId)
+}
 
 // InlineQueryResultCachedPhoto Represents a link to a photo stored on the Telegram servers.
 // By default, this photo will be sent by the user with an optional caption.
@@ -2395,6 +2414,9 @@ func (impl *InlineQueryResultCachedPhoto) Download(ctx context.Context, path str
 func (impl *InlineQueryResultCachedPhoto) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.PhotoFileId, dirAndPattern...)
 }
+func (impl *InlineQueryResultCachedPhoto) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.PhotoFileId)
+}
 
 // InlineQueryResultCachedSticker Represents a link to a sticker stored on the Telegram servers. By default, this sticker will be sent by the user.
 // Alternatively, you can use input_message_content to send a message with the specified content instead of the sticker.
@@ -2417,6 +2439,9 @@ func (impl *InlineQueryResultCachedSticker) Download(ctx context.Context, path s
 func (impl *InlineQueryResultCachedSticker) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.StickerFileId, dirAndPattern...)
 }
+func (impl *InlineQueryResultCachedSticker) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.StickerFileId)
+}
 
 // InlineQueryResultCachedVideo Represents a link to a video file stored on the Telegram servers.
 // By default, this video file will be sent by the user with an optional caption.
@@ -2452,6 +2477,9 @@ func (impl *InlineQueryResultCachedVideo) Download(ctx context.Context, path str
 func (impl *InlineQueryResultCachedVideo) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.VideoFileId, dirAndPattern...)
 }
+func (impl *InlineQueryResultCachedVideo) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.VideoFileId)
+}
 
 // InlineQueryResultCachedVoice Represents a link to a voice message stored on the Telegram servers.
 // By default, this voice message will be sent by the user.
@@ -2483,6 +2511,9 @@ func (impl *InlineQueryResultCachedVoice) Download(ctx context.Context, path str
 func (impl *InlineQueryResultCachedVoice) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.VoiceFileId, dirAndPattern...)
 }
+func (impl *InlineQueryResultCachedVoice) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.VoiceFileId)
+}
 
 // InlineQueryResultContact Represents a contact with a phone number. By default, this contact will be sent by the user.
 // Alternatively, you can use input_message_content to send a message with the specified content instead of the contact.
@@ -4196,6 +4227,9 @@ func (impl *PassportFile) Download(ctx context.Context, path string) error {
 func (impl *PassportFile) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.FileId, dirAndPattern...)
 }
+func (impl *PassportFile) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.FileId)
+}
 
 // Photo Represents a photo to be sent.
 type Photo struct {
@@ -4239,6 +4273,9 @@ func (impl *PhotoSize) Download(ctx context.Context, path string) error {
 func (impl *PhotoSize) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.FileId, dirAndPattern...)
 }
+func (impl *PhotoSize) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.FileId)
+}
 
 // Poll This object contains information about a poll.
 type Poll struct {
@@ -4668,6 +4705,9 @@ func (impl *Sticker) Download(ctx context.Context, path string) error {
 func (impl *Sticker) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.FileId, dirAndPattern...)
 }
+func (impl *Sticker) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.FileId)
+}
 
 // StickerSet This object represents a sticker set.
 type StickerSet struct {
@@ -4763,6 +4803,9 @@ func (impl *TelegramAnimation) Download(ctx context.Context, path string) error
 func (impl *TelegramAnimation) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.FileId, dirAndPattern...)
 }
+func (impl *TelegramAnimation) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.FileId)
+}
 
 // TelegramAudio This object represents an audio file to be treated as music by the Telegram clients.
 type TelegramAudio struct {
@@ -4795,6 +4838,9 @@ func (impl *TelegramAudio) Download(ctx context.Context, path string) error {
 func (impl *TelegramAudio) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.FileId, dirAndPattern...)
 }
+func (impl *TelegramAudio) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.FileId)
+}
 
 // TelegramDocument This object represents a general file (as opposed to photos, voice messages and audio files).
 type TelegramDocument struct {
@@ -4821,6 +4867,9 @@ func (impl *TelegramDocument) Download(ctx context.Context, path string) error {
 func (impl *TelegramDocument) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.FileId, dirAndPattern...)
 }
+func (impl *TelegramDocument) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.FileId)
+}
 
 // TelegramVideo This object represents a video file.
 type TelegramVideo struct {
@@ -4853,6 +4902,9 @@ func (impl *TelegramVideo) Download(ctx context.Context, path string) error {
 func (impl *TelegramVideo) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.FileId, dirAndPattern...)
 }
+func (impl *TelegramVideo) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.FileId)
+}
 
 // TextQuote This object contains information about the quoted part of a message that is replied to by the given message.
 type TextQuote struct {
@@ -5240,6 +5292,9 @@ func (impl *VideoNote) Download(ctx context.Context, path string) error {
 func (impl *VideoNote) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.FileId, dirAndPattern...)
 }
+func (impl *VideoNote) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.FileId)
+}
 
 // Voice This object represents a voice note.
 type Voice struct {
@@ -5264,6 +5319,9 @@ func (impl *Voice) Download(ctx context.Context, path string) error {
 func (impl *Voice) DownloadTemp(ctx context.Context, dirAndPattern ...string) (filename string, err error) {
 	return GenericDownloadTemp(ctx, impl.FileId, dirAndPattern...)
 }
+func (impl *Voice) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	return GenericDownloadReader(ctx, impl.FileId)
+}
 
 // WebAppData Describes data sent from a Web App to the bot.
 type WebAppData struct {
@@ -13,6 +13,7 @@ import (
 type FilterFunc func(ctx context.Context, upd *Update) bool
 type HandlerFunc func(ctx context.Context, upd *Update) error
 type OnErrorFunc func(ctx context.Context, err error)
+type MiddlewareFunc func(next HandlerFunc) HandlerFunc
 
 type Bot struct {
 	context           context.Context
@@ -23,6 +24,10 @@ type Bot struct {
 	pipelineLock   sync.Mutex
 	pipeline       pipe
 	plugins        map[PluginHookType][]Plugin
+	middlewares    []MiddlewareFunc
+	commands       commandRegistry
+	asks           askRegistry
+	keyboards      keyboardRegistry
 	defaultHandler HandlerFunc
 
 	syncHandling  bool
@@ -117,12 +122,49 @@ func (bot *Bot) Plugin(plugin ...Plugin) *Bot {
 	return bot
 }
 
+// Use wraps handling of every update (the whole pipeline, regardless of where Use is called), the first middleware
+// is the outermost one. Middleware may enrich the context or stop the update by not calling next.
+//
+// Example:
+//
+//	bot.Use(func(next tg.HandlerFunc) tg.HandlerFunc {
+//		return func(ctx context.Context, upd *tg.Update) error {
+//			start := time.Now()
+//			defer func() { slog.Info("handled", "update_id", upd.UpdateId, "took", time.Since(start)) }()
+//			return next(ctx, upd)
+//		}
+//	})
+func (bot *Bot) Use(middleware ...MiddlewareFunc) *Bot {
+	bot.pipelineLock.Lock()
+	defer bot.pipelineLock.Unlock()
+	bot.middlewares = append(bot.middlewares, middleware...)
+	return bot
+}
+
 // Scheduler ensures no 429 Too Many Requests.
 func (bot *Bot) Scheduler(scheduler ...Scheduler) *Bot {
 	bot.context = context.WithValue(bot.context, ContextScheduler, at(scheduler, 0, NewScheduler()))
 	return bot
 }
 
+// FileCache makes repeatedly sent local files upload only once, i.e. tg.FromDisk("banner.png") is sent by its file_id after the first upload.
+func (bot *Bot) FileCache(cache ...*FileCache) *Bot {
+	bot.context = context.WithValue(bot.context, ContextFileCache, at(cache, 0, NewFileCache()))
+	return bot
+}
+
+// DownloadDedupe makes downloads of the same file (by FileUniqueId) copy the previously downloaded one instead.
+func (bot *Bot) DownloadDedupe() *Bot {
+	bot.context = context.WithValue(bot.context, ContextDownloadDedupe, &downloadDedupe{paths: map[string]string{}})
+	return bot
+}
+
+// I18n enables localized replies (see I18n, T, TN and CommonTextReplyT), the locale is resolved for every update.
+func (bot *Bot) I18n(i18n *I18n) *Bot {
+	bot.context = context.WithValue(bot.context, ContextI18n, i18n)
+	return bot.Use(i18n.Middleware)
+}
+
 // ContextWithCancel build new Context with a fresh timeout.
 func (bot *Bot) ContextWithCancel() (ctx context.Context, cancel context.CancelFunc) {
 	if bot.contextTimeout == 0 {
@@ -181,7 +223,15 @@ func (bot *Bot) Start(updates ...*Update) {
 	defer cancel()
 	bot.contextCancelFunc = cancel
 	bot.stopUpdates = make(chan bool)
+	bot.keyboardsBranch()
 
+	if !bot.commands.Empty() {
+		commandsCtx, commandsCancel := bot.ContextWithCancel()
+		if err := bot.SyncCommands(commandsCtx); err != nil {
+			bot.pluginsHook(PluginHookOnError, &PluginHookContextOnError{commandsCtx, bot, err})
+		}
+		commandsCancel()
+	}
 	bot.handleUpdates(ctx, updates)
 
 	for {
@@ -252,10 +302,20 @@ func (bot *Bot) handle(updatesCancelContextWg *sync.WaitGroup, ctx context.Conte
 		}
 	}()
 
-	if !bot.handlePipe(&bot.pipeline, ctx, update) && bot.defaultHandler != nil {
-		if err := bot.defaultHandler(ctx, update); err != nil {
-			bot.pluginsHook(PluginHookOnError, &PluginHookContextOnError{ctx, bot, err})
+	handler := func(ctx context.Context, update *Update) error {
+		if bot.asks.Deliver(ctx, update) {
+			return nil
+		}
+		if !bot.handlePipe(&bot.pipeline, ctx, update) && bot.defaultHandler != nil {
+			return bot.defaultHandler(ctx, update)
 		}
+		return nil
+	}
+	for _, middleware := range slices.Backward(bot.middlewares) {
+		handler = middleware(handler)
+	}
+	if err := handler(ctx, update); err != nil {
+		bot.pluginsHook(PluginHookOnError, &PluginHookContextOnError{ctx, bot, err})
 	}
 }
 
@@ -272,6 +332,7 @@ func (bot *Bot) handlePipe(pipe *pipe, ctx context.Context, update *Update) (han
 		bot.pluginsHook(PluginHookOnHandleStart, &PluginHookContextOnHandleStart{ctx, bot, update, pipe.Handle})
 		err := pipe.Handle(ctx, update)
 		if err != nil {
+			handlerFailed(ctx, err)
 			bot.pluginsHook(PluginHookOnError, &PluginHookContextOnError{ctx, bot, err})
 		}
 		bot.pluginsHook(PluginHookOnHandleFinish, &PluginHookContextOnHandleFinish{ctx, bot, update, pipe.Handle, err})
@@ -0,0 +1,150 @@
+package tg
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// OptAsk configures Ask.
+type OptAsk struct {
+	// Filter makes only matching updates answer the question, i.e. tg.OnPhoto, the rest goes through the pipeline.
+	Filter FilterFunc
+	// CancelCommands stop waiting with ErrorAskCanceled, i.e. "/cancel".
+	CancelCommands []string
+	// TimeoutReply is sent on timeout, nothing is sent if empty.
+	TimeoutReply string
+	// Message options of the question.
+	Message *OptSendMessage
+}
+
+// Ask sends the question (if it's not empty) and waits for the next update of the same chat+user, the answer
+// goes through middlewares (Bot.Use, e.g. CallbackSigner verifies callback data) and is intercepted before
+// the pipeline sees it. Zero timeout waits until the handler's context is done.
+// Ask needs concurrent handling: with Config.SyncHandling the answer would never be dispatched.
+//
+// Example:
+//
+//	bot.Command("/avatar", func(ctx context.Context, upd *tg.Update) error {
+//		answer, err := tg.Ask(ctx, upd, "send me a photo", time.Minute, &tg.OptAsk{
+//			Filter:         tg.OnPhoto,
+//			CancelCommands: []string{"/cancel"},
+//			TimeoutReply:   "too slow, try again later",
+//		})
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	})
+func Ask(ctx context.Context, upd *Update, question string, timeout time.Duration, opts ...*OptAsk) (*Update, error) {
+	bot, ok := ctx.Value(ContextBotInstance).(*Bot)
+	if !ok {
+		return nil, &Error{Description: "tg.Ask: bot not found in context"}
+	}
+	if bot.syncHandling {
+		return nil, &Error{Description: "tg.Ask: not supported with sync handling"}
+	}
+	opt := at(opts, 0, &OptAsk{})
+
+	waiter := &askWaiter{
+		key:     askKey(upd),
+		filter:  opt.Filter,
+		cancel:  opt.CancelCommands,
+		answers: make(chan *Update, 1),
+	}
+	bot.asks.Add(waiter)
+	defer bot.asks.Remove(waiter)
+
+	if question != "" {
+		message := deref(opt.Message)
+		if _, err := SendMessage(ctx, getChatId(upd), question, &message); err != nil {
+			return nil, err
+		}
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	select {
+	case answer := <-waiter.answers:
+		for _, command := range opt.CancelCommands {
+			if OnCommand(command)(ctx, answer) {
+				return answer, &ErrorAskCanceled{Command: command}
+			}
+		}
+		return answer, nil
+	case <-deadline:
+		if opt.TimeoutReply != "" {
+			if _, err := SendMessage(ctx, getChatId(upd), opt.TimeoutReply); err != nil {
+				return nil, err
+			}
+		}
+		return nil, &ErrorAskTimeout{Timeout: timeout}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// askRegistry keeps handlers waiting in Ask, Deliver is called for every update before the pipeline.
+type askRegistry struct {
+	mutex   sync.Mutex
+	waiters []*askWaiter
+}
+
+type askWaiter struct {
+	key     string
+	filter  FilterFunc
+	cancel  []string
+	answers chan *Update
+}
+
+func (registry *askRegistry) Add(waiter *askWaiter) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.waiters = append(registry.waiters, waiter)
+}
+
+func (registry *askRegistry) Remove(waiter *askWaiter) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.waiters = slices.DeleteFunc(registry.waiters, func(w *askWaiter) bool { return w == waiter })
+}
+
+// Deliver passes the update to the first matching waiter (and removes it), false if nobody waits for the update.
+func (registry *askRegistry) Deliver(ctx context.Context, upd *Update) bool {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if len(registry.waiters) == 0 {
+		return false
+	}
+
+	key := askKey(upd)
+	for i, waiter := range registry.waiters {
+		if waiter.key != key || !waiter.matches(ctx, upd) {
+			continue
+		}
+		registry.waiters = slices.Delete(registry.waiters, i, i+1)
+		waiter.answers <- upd
+		return true
+	}
+	return false
+}
+
+func (waiter *askWaiter) matches(ctx context.Context, upd *Update) bool {
+	for _, command := range waiter.cancel {
+		if OnCommand(command)(ctx, upd) {
+			return true
+		}
+	}
+	return waiter.filter == nil || filterWrappedPanics(waiter.filter, ctx, upd)
+}
+
+func askKey(upd *Update) string {
+	return fmt.Sprintf("%d:%d", getChatId(upd), getSenderId(upd))
+}
@@ -0,0 +1,129 @@
+package tg
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// CommandDescriptions are descriptions of a command per language code, "" is the default for every other language.
+//
+// Example:
+//
+//	tg.CommandDescriptions{"": "Start the bot", "ru": "Запустить бота", "uk": "Запустити бота"}
+type CommandDescriptions map[string]string
+
+// commandRegistry keeps commands of the menu, they are synced with Telegram on Bot.Start.
+type commandRegistry struct {
+	mutex    sync.Mutex
+	commands []*commandRegistryEntry
+}
+
+type commandRegistryEntry struct {
+	Command      string
+	Descriptions CommandDescriptions
+	Scopes       []BotCommandScope
+	Definition   *CommandDefinition
+}
+
+// CommandLocalized handles the command as Command does and adds it to the menu with localized descriptions,
+// scopes default to BotCommandScopeDefault. The menu is synced on Start: for every language and scope
+// only changed lists are pushed with SetMyCommands (see SyncCommands).
+//
+// Example:
+//
+//	bot.
+//		CommandLocalized("/start", tg.CommandDescriptions{"": "Start", "ru": "Начать"}, start).
+//		CommandLocalized("/ban", tg.CommandDescriptions{"": "Ban the sender"}, ban, &tg.BotCommandScopeAllChatAdministrators{}).
+//		Start()
+func (bot *Bot) CommandLocalized(command string, descriptions CommandDescriptions, handler HandlerFunc, scopes ...BotCommandScope) *Bot {
+	bot.commands.Add(&commandRegistryEntry{Command: command, Descriptions: descriptions, Scopes: scopes})
+	return bot.Command(command, handler)
+}
+
+// SyncCommands pushes the menu of CommandLocalized commands for every language/scope pair, which differs from GetMyCommands.
+func (bot *Bot) SyncCommands(ctx context.Context) error {
+	return bot.commands.Sync(ctx)
+}
+
+func (registry *commandRegistry) Add(entry *commandRegistryEntry) {
+	entry.Command = strings.TrimPrefix(entry.Command, "/")
+	if len(entry.Scopes) == 0 {
+		entry.Scopes = []BotCommandScope{&BotCommandScopeDefault{Type: "default"}}
+	}
+	entry.Scopes = slices.Clone(entry.Scopes)
+	for i, scope := range entry.Scopes {
+		entry.Scopes[i] = defaults(scope)
+	}
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.commands = append(registry.commands, entry)
+}
+
+// Menus returns the expected menu for every scope (by its json) and language used by commands of the scope.
+func (registry *commandRegistry) Menus() (scopes map[string]BotCommandScope, menus map[string]map[string][]*BotCommand) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	scopes, menus = map[string]BotCommandScope{}, map[string]map[string][]*BotCommand{}
+	for _, entry := range registry.commands {
+		for _, scope := range entry.Scopes {
+			key := commandScopeKey(scope)
+			scopes[key] = scope
+			if menus[key] == nil {
+				menus[key] = map[string][]*BotCommand{}
+			}
+			for language := range entry.Descriptions {
+				menus[key][language] = []*BotCommand{}
+			}
+		}
+	}
+
+	for _, entry := range registry.commands {
+		for _, scope := range entry.Scopes {
+			key := commandScopeKey(scope)
+			for language := range menus[key] {
+				description, ok := entry.Descriptions[language]
+				if !ok {
+					description, ok = entry.Descriptions[""]
+				}
+				if ok {
+					menus[key][language] = append(menus[key][language], &BotCommand{Command: entry.Command, Description: description})
+				}
+			}
+		}
+	}
+	return scopes, menus
+}
+
+func (registry *commandRegistry) Sync(ctx context.Context) error {
+	scopes, menus := registry.Menus()
+	for key, languages := range menus {
+		for language, commands := range languages {
+			current, err := GetMyCommands(ctx, &OptGetMyCommands{Scope: scopes[key], LanguageCode: language})
+			if err != nil {
+				return err
+			}
+			if slices.EqualFunc(current, commands, func(a *BotCommand, b *BotCommand) bool { return *a == *b }) {
+				continue
+			}
+			if _, err = SetMyCommands(ctx, commands, &OptSetMyCommands{Scope: scopes[key], LanguageCode: language}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (registry *commandRegistry) Empty() bool {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	return len(registry.commands) == 0
+}
+
+func commandScopeKey(scope BotCommandScope) string {
+	data, _ := json.Marshal(scope)
+	return string(data)
+}
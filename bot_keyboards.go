@@ -0,0 +1,124 @@
+package tg
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// keyboardRegistry dispatches callbacks of registered keyboards by their ids through a single branch.
+type keyboardRegistry struct {
+	mutex     sync.Mutex
+	branch    sync.Once
+	keyboards map[uint32]*keyboardRegistryEntry
+}
+
+type keyboardRegistryEntry struct {
+	handler   HandlerFunc
+	expiresAt time.Time
+}
+
+// RegisterKeyboard makes the bot handle callbacks of the keyboard, registering the same keyboard again prolongs
+// Keyboard.TTL. Keyboards without TTL are identified by their layout, so an equal keyboard replaces the handlers
+// and buttons of messages sent before a restart keep working. Keyboards with TTL are unique per Keyboard value, so
+// keyboards built in handlers never share handlers, even with the same texts and data (e.g. "Delete" of two items).
+// All keyboards share a single OnCallback branch at the head of the pipeline, so keyboards built in handlers work
+// regardless of handlers declared below.
+//
+// Example:
+//
+//	bot.Command("/vote", func(ctx context.Context, upd *tg.Update) error {
+//		keyboard := &tg.Keyboard{Layout: ..., TTL: time.Hour}
+//		_, err := tg.SendMessage(ctx, upd.Message.Chat.Id, "vote!", &tg.OptSendMessage{ReplyMarkup: keyboard.BuildRegister(ctx)})
+//		return err
+//	})
+func (bot *Bot) RegisterKeyboard(keyboard *Keyboard) *Bot {
+	keyboard.init()
+	bot.registerCallbacks(keyboard.id, keyboard.HandlerFunc(), keyboard.TTL)
+	return bot
+}
+
+// registerCallbacks makes the keyboard registry handle callback data with the keyboard id, see RegisterKeyboard.
+func (bot *Bot) registerCallbacks(keyboardId uint32, handler HandlerFunc, ttl time.Duration) {
+	bot.keyboardsBranch()
+	bot.keyboards.Add(keyboardId, handler, ttl)
+}
+
+// keyboardsBranch inserts the branch of the registry at the head of the pipeline, Start does it before handling,
+// so keyboards registered in handlers never change the pipeline while it is read.
+func (bot *Bot) keyboardsBranch() {
+	bot.keyboards.branch.Do(func() {
+		bot.pipelineLock.Lock()
+		defer bot.pipelineLock.Unlock()
+		branch := Branch().Filter(bot.keyboards.filter).Handle(bot.keyboards.handle)
+		bot.pipeline.Next = &pipe{Branch: branch.pipeline, Next: bot.pipeline.Next}
+	})
+}
+
+// UnregisterKeyboard stops handling callbacks of the keyboard, they go through the pipeline as any other update.
+func (bot *Bot) UnregisterKeyboard(keyboard *Keyboard) *Bot {
+	bot.keyboards.Remove(keyboard)
+	return bot
+}
+
+func (registry *keyboardRegistry) Add(keyboardId uint32, handler HandlerFunc, ttl time.Duration) {
+	entry := &keyboardRegistryEntry{handler: handler}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if registry.keyboards == nil {
+		registry.keyboards = map[uint32]*keyboardRegistryEntry{}
+	}
+	now := time.Now()
+	for id, entry := range registry.keyboards {
+		if entry.expired(now) {
+			delete(registry.keyboards, id)
+		}
+	}
+	registry.keyboards[keyboardId] = entry
+}
+
+func (registry *keyboardRegistry) Remove(keyboard *Keyboard) {
+	keyboard.init()
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	delete(registry.keyboards, keyboard.id)
+}
+
+// Get returns the handler of a registered (and not expired) keyboard by the callback data.
+func (registry *keyboardRegistry) Get(callbackData string) (HandlerFunc, bool) {
+	keyboardId, _, ok := decodeCallbackDataHeader(callbackData)
+	if !ok {
+		return nil, false
+	}
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	entry, ok := registry.keyboards[keyboardId]
+	if !ok || entry.expired(time.Now()) {
+		return nil, false
+	}
+	return entry.handler, true
+}
+
+func (registry *keyboardRegistry) filter(ctx context.Context, upd *Update) bool {
+	if !OnCallback(ctx, upd) {
+		return false
+	}
+	_, ok := registry.Get(upd.CallbackQuery.Data)
+	return ok
+}
+
+func (registry *keyboardRegistry) handle(ctx context.Context, upd *Update) error {
+	handler, ok := registry.Get(upd.CallbackQuery.Data)
+	if !ok {
+		return nil
+	}
+	return handler(ctx, upd)
+}
+
+func (entry *keyboardRegistryEntry) expired(now time.Time) bool {
+	return !entry.expiresAt.IsZero() && now.After(entry.expiresAt)
+}
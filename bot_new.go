@@ -21,6 +21,10 @@ const (
 	EnvApiURL           = "API_URL"
 	EnvApiExtraHeaders  = "EXTRA_HEADERS"
 	EnvDownloadType     = "DOWNLOAD_TYPE"
+	EnvDownloadMaxSize  = "DOWNLOAD_MAX_SIZE"
+	EnvLocalServer      = "LOCAL_SERVER"
+	// EnvLocalPathMapping is a json object of local Bot API server path prefixes to the paths of this process.
+	EnvLocalPathMapping = "LOCAL_PATH_MAPPING"
 	// EnvOnError is either ignore/log/exit.
 	EnvOnError = "ON_ERROR"
 
@@ -42,23 +46,26 @@ var (
 type DownloadType int
 
 const (
-	DownloadTypeUnspecified DownloadType = iota // calls default strategy (classic)
+	DownloadTypeUnspecified DownloadType = iota // calls default strategy (local move for local server files, classic otherwise)
 	DownloadTypeClassic                         // calls fileDownloadClassic
 	DownloadTypeLocalMove                       // calls fileDownloadLocalMove
 	DownloadTypeLocalCopy                       // calls fileDownloadLocalCopy
 )
 
 type Config struct {
-	Token         string            `json:"token"`
-	TokenTesting  string            `json:"token_testing"`
-	ApiURL        string            `json:"api_url,omitempty"`
-	TimeoutHandle time.Duration     `json:"timeout,omitempty"`
-	TimeoutPoll   time.Duration     `json:"timeout_poll,omitempty"`
-	SyncHandling  bool              `json:"sync,omitempty"`
-	DownloadType  DownloadType      `json:"download_type,omitempty"`
-	OnError       OnErrorFunc       `json:"-"`
-	OnErrorByType string            `json:"on_error,omitempty"`
-	ExtraHeaders  map[string]string `json:"extra_headers,omitempty"`
+	Token            string            `json:"token"`
+	TokenTesting     string            `json:"token_testing"`
+	ApiURL           string            `json:"api_url,omitempty"`
+	TimeoutHandle    time.Duration     `json:"timeout,omitempty"`
+	TimeoutPoll      time.Duration     `json:"timeout_poll,omitempty"`
+	SyncHandling     bool              `json:"sync,omitempty"`
+	DownloadType     DownloadType      `json:"download_type,omitempty"`
+	DownloadMaxSize  int64             `json:"download_max_size,omitempty"`
+	LocalServer      bool              `json:"local_server,omitempty"`
+	LocalPathMapping map[string]string `json:"local_path_mapping,omitempty"`
+	OnError          OnErrorFunc       `json:"-"`
+	OnErrorByType    string            `json:"on_error,omitempty"`
+	ExtraHeaders     map[string]string `json:"extra_headers,omitempty"`
 
 	buildType int
 }
@@ -108,31 +115,33 @@ func TryNew(cfg *Config) (*Bot, error) {
 		ctx = context.WithValue(ctx, ContextExtraHeaders, cfg.ExtraHeaders)
 	}
 
-	switch cfg.DownloadType {
-	case DownloadTypeUnspecified:
-		if cfg.ApiURL == "" {
-			ctx = context.WithValue(ctx, ContextFileDownloadType, fileDownloadClassic)
-			break
-		}
-		ctx = context.WithValue(ctx, ContextFileDownloadType, fileDownloadClassic)
-		link, err := url.Parse(cfg.ApiURL)
-		if err != nil {
+	if cfg.ApiURL != "" {
+		if _, err := url.Parse(cfg.ApiURL); err != nil {
 			return nil, fmt.Errorf("env: error '%s' while parsing '%s'", err.Error(), EnvApiURL)
 		}
-		switch strings.ToLower(link.Hostname()) {
-		case "localhost", "127.0.0.1":
-			ctx = context.WithValue(ctx, ContextFileDownloadType, fileDownloadLocalMove)
-		}
+	}
+
+	switch cfg.DownloadType {
+	case DownloadTypeUnspecified:
+		// Local Bot API server is detected by absolute file paths, see IsLocalServerFile.
+		ctx = withDownloadType(ctx, fileDownloadAuto, fileReaderAuto)
 	case DownloadTypeClassic:
-		ctx = context.WithValue(ctx, ContextFileDownloadType, fileDownloadClassic)
+		ctx = withDownloadType(ctx, fileDownloadClassic, fileReaderClassic)
 	case DownloadTypeLocalMove:
-		ctx = context.WithValue(ctx, ContextFileDownloadType, fileDownloadLocalMove)
+		ctx = withDownloadType(ctx, fileDownloadLocalMove, fileReaderLocalMove)
 	case DownloadTypeLocalCopy:
-		ctx = context.WithValue(ctx, ContextFileDownloadType, fileDownloadLocalCopy)
+		ctx = withDownloadType(ctx, fileDownloadLocalCopy, fileReaderLocalCopy)
 	default:
 		return nil, fmt.Errorf("config: invalid download type: %#v", cfg.DownloadType)
 	}
 
+	if cfg.LocalServer || len(cfg.LocalPathMapping) > 0 {
+		ctx = WithLocalServer(cfg.LocalPathMapping)(ctx)
+	}
+	if cfg.DownloadMaxSize > 0 {
+		ctx = WithDownloadMaxSize(cfg.DownloadMaxSize)(ctx)
+	}
+
 	onError, err := buildPluginsOnError(cfg)
 	if err != nil {
 		return nil, err
@@ -158,6 +167,11 @@ func TryNew(cfg *Config) (*Bot, error) {
 	return result, nil
 }
 
+func withDownloadType(ctx context.Context, downloader downloaderFunc, reader readerFunc) context.Context {
+	ctx = context.WithValue(ctx, ContextFileDownloadType, downloader)
+	return context.WithValue(ctx, ContextFileReaderType, reader)
+}
+
 func buildPluginsOnError(cfg *Config) ([]Plugin, error) {
 	onError := []Plugin{}
 	if cfg.OnError != nil {
@@ -239,6 +253,13 @@ func configFromEnv() (config *Config, err error) {
 		}
 	}
 
+	var downloadMaxSize int64
+	if env, ok := lookupEnv(EnvDownloadMaxSize); ok {
+		if downloadMaxSize, err = strconv.ParseInt(strings.TrimSpace(env), 10, 64); err != nil {
+			return nil, fmt.Errorf("env: invalid '%s' (at %s), err '%s'", env, EnvDownloadMaxSize, err.Error())
+		}
+	}
+
 	headers := map[string]string{}
 	if env, ok := lookupEnv(EnvApiExtraHeaders); ok {
 		if err := json.Unmarshal([]byte(env), &headers); err != nil {
@@ -246,19 +267,31 @@ func configFromEnv() (config *Config, err error) {
 		}
 	}
 
+	localPathMapping := map[string]string{}
+	if env, ok := lookupEnv(EnvLocalPathMapping); ok {
+		if err := json.Unmarshal([]byte(env), &localPathMapping); err != nil {
+			return nil, err
+		}
+	}
+
 	config = &Config{
-		Token:         getEnv(EnvToken),
-		TokenTesting:  getEnv(EnvTokenTesting),
-		ApiURL:        getEnv(EnvApiURL),
-		DownloadType:  downloadType,
-		OnError:       nil,
-		OnErrorByType: strings.ToLower(getEnv(EnvOnError)),
-		ExtraHeaders:  headers,
-		buildType:     buildTypeEnv,
+		Token:            getEnv(EnvToken),
+		TokenTesting:     getEnv(EnvTokenTesting),
+		ApiURL:           getEnv(EnvApiURL),
+		DownloadType:     downloadType,
+		DownloadMaxSize:  downloadMaxSize,
+		LocalPathMapping: localPathMapping,
+		OnError:          nil,
+		OnErrorByType:    strings.ToLower(getEnv(EnvOnError)),
+		ExtraHeaders:     headers,
+		buildType:        buildTypeEnv,
 	}
 	if config.SyncHandling, err = parseFromEnvBool(EnvSyncedHandle, false); err != nil {
 		return nil, err
 	}
+	if config.LocalServer, err = parseFromEnvBool(EnvLocalServer, false); err != nil {
+		return nil, err
+	}
 	if config.TimeoutHandle, err = parseFromEnvDuration(EnvTimeoutHandle, -1); err != nil {
 		return nil, err
 	}
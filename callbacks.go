@@ -1,12 +1,17 @@
 package tg
 
 import (
+	"cmp"
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"hash/fnv"
 	"log/slog"
+	"math/rand/v2"
+	"strconv"
 	"sync"
+	"time"
 )
 
 type ButtonI interface {
@@ -30,15 +35,19 @@ func (b *Button) HandlerFunc() HandlerFunc {
 	}
 }
 
+// CallbackButton calls Handler on press, the handler gets Data as CallbackQuery.Data. Within a Keyboard, a button
+// without Data gets its position in the keyboard (1-based, row by row) instead, e.g. "1".
 type CallbackButton struct {
 	Text       string
+	Data       string
 	Handler    HandlerFunc
 	OnComplete *OptAnswerCallbackQuery
 }
 
 func (c *CallbackButton) Build() *InlineKeyboardButton {
 	return &InlineKeyboardButton{
-		Text: c.Text,
+		Text:         c.Text,
+		CallbackData: c.Data,
 	}
 }
 
@@ -55,10 +64,16 @@ func (c *CallbackButton) HandlerFunc() HandlerFunc {
 	}
 }
 
+// Keyboard is an inline keyboard with handlers of its buttons. Button's data is sent within the callback data
+// (up to 55 bytes), longer data is kept in the Storage and referenced by a short id.
+// TTL makes Bot forget keyboards built in handlers, zero TTL keeps the keyboard registered forever
+// (see Bot.RegisterKeyboard for ids of keyboards with and without TTL).
 type Keyboard struct {
-	Layout [][]ButtonI
-	idOnce sync.Once
-	id     string
+	Layout  [][]ButtonI
+	Storage CallbackDataStorage
+	TTL     time.Duration
+	idOnce  sync.Once
+	id      uint32
 }
 
 func (k *Keyboard) init() {
@@ -73,30 +88,40 @@ func (k *Keyboard) init() {
 		data, _ := json.Marshal(result)
 		hash := fnv.New32()
 		_, _ = hash.Write(data)
-		k.id = fmt.Sprintf("%x", hash.Sum32())
+		if k.TTL > 0 {
+			// Temporary keyboards are built per message, the same layout may carry handlers of another item.
+			_, _ = hash.Write(binary.BigEndian.AppendUint64(nil, rand.Uint64()))
+		}
+		k.id = hash.Sum32()
 	})
 }
 
-type keyboardSchema struct {
-	KeyboardId string `json:"K"`
-	ButtonId   int    `json:"B"`
-	Data       string `json:"D"`
-}
-
 func (k *Keyboard) Branch() (FilterFunc, HandlerFunc) {
 	return k.FilterFunc(), k.HandlerFunc()
 }
 
+// BuildRegister builds the keyboard and registers it in the bot of the context (see Bot.RegisterKeyboard).
 func (k *Keyboard) BuildRegister(ctx context.Context) *InlineKeyboardMarkup {
 	bot, ok := ctx.Value(ContextBotInstance).(*Bot)
 	if !ok {
 		return nil
 	}
-	bot.Branch(k.FilterFunc(), k.HandlerFunc())
+	bot.RegisterKeyboard(k)
 	return k.Build()
 }
 
+// Build is TryBuild, but it panics if the data of a button does not fit into the callback data.
 func (k *Keyboard) Build() *InlineKeyboardMarkup {
+	markup, err := k.TryBuild()
+	if err != nil {
+		panic(err)
+	}
+	return markup
+}
+
+// TryBuild encodes buttons' data into the callback data, ErrorCallbackDataTooLong is returned if the data
+// does not fit and there is no Storage.
+func (k *Keyboard) TryBuild() (*InlineKeyboardMarkup, error) {
 	k.init()
 	result := make([][]*InlineKeyboardButton, len(k.Layout))
 	buttonId := 0
@@ -104,25 +129,30 @@ func (k *Keyboard) Build() *InlineKeyboardMarkup {
 		result[i] = make([]*InlineKeyboardButton, len(buttons))
 		for j, button := range buttons {
 			buttonId++
-			built := button.Build()
-			data, _ := json.Marshal(keyboardSchema{k.id, buttonId, built.Text})
-			built.CallbackData = string(data)
-			result[i][j] = built
+			// Build of a Button returns the button itself, so the copy is encoded.
+			built := deref(button.Build())
+			data := &callbackData{KeyboardId: k.id, ButtonId: buttonId, Data: built.CallbackData}
+			if data.Data == "" {
+				data.Data = strconv.Itoa(buttonId)
+			}
+			encoded, err := data.Encode(k.Storage)
+			if err != nil {
+				return nil, err
+			}
+			built.CallbackData = encoded
+			result[i][j] = &built
 		}
 	}
 	return &InlineKeyboardMarkup{
 		InlineKeyboard: result,
-	}
+	}, nil
 }
 
 func (k *Keyboard) FilterFunc() FilterFunc {
 	k.init()
 	return All(OnCallback, func(ctx context.Context, upd *Update) bool {
-		var data keyboardSchema
-		if err := json.Unmarshal([]byte(upd.CallbackQuery.Data), &data); err != nil {
-			return false
-		}
-		return k.id == data.KeyboardId
+		keyboardId, _, ok := decodeCallbackDataHeader(upd.CallbackQuery.Data)
+		return ok && k.id == keyboardId
 	})
 }
 
@@ -130,21 +160,27 @@ func (k *Keyboard) HandlerFunc() HandlerFunc {
 	k.init()
 	buttonId := 0
 	hashToButton := map[int]HandlerFunc{}
+	buttonData := map[int]string{}
 	for _, buttons := range k.Layout {
 		for _, button := range buttons {
 			buttonId++
 			hashToButton[buttonId] = button.HandlerFunc()
+			buttonData[buttonId] = cmp.Or(button.Build().CallbackData, strconv.Itoa(buttonId))
 		}
 	}
 	return func(ctx context.Context, upd *Update) error {
-		var data keyboardSchema
-		if err := json.Unmarshal([]byte(upd.CallbackQuery.Data), &data); err != nil {
+		data, err := decodeCallbackData(upd.CallbackQuery.Data, k.Storage)
+		if err != nil {
 			return err
 		}
 		handler, ok := hashToButton[data.ButtonId]
 		if !ok {
 			return fmt.Errorf("unknown button %d", data.ButtonId)
 		}
+		// The header is easy to copy, so only the data of the button itself is accepted (forged data is not).
+		if data.Data != buttonData[data.ButtonId] {
+			return &ErrorCallbackDataInvalid{Data: upd.CallbackQuery.Data, Reason: "data of another button"}
+		}
 		if upd.CallbackQuery != nil {
 			upd.CallbackQuery.Data = data.Data
 		}
@@ -0,0 +1,58 @@
+package tg
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+const defaultCallbackError = "Something went wrong, try again later."
+
+// callbackAnswer tracks AnswerCallbackQuery calls and handler errors while a callback query is handled.
+type callbackAnswer struct {
+	answered atomic.Bool
+	failed   atomic.Bool
+}
+
+// AutoAnswerCallbacks answers callback queries, which handlers did not answer, so the client stops the loading
+// animation. If a handler failed, the user gets the alert (the default one if empty).
+//
+// Example:
+//
+//	bot.
+//		AutoAnswerCallbacks("Oops, try again later").
+//		Branch(tg.OnCallback, func(ctx context.Context, upd *tg.Update) error {
+//			return doSomething() // no AnswerCallbackQuery needed.
+//		})
+func (bot *Bot) AutoAnswerCallbacks(errorAlert ...string) *Bot {
+	alert := at(errorAlert, 0, defaultCallbackError)
+	return bot.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, upd *Update) error {
+			if !OnCallback(ctx, upd) {
+				return next(ctx, upd)
+			}
+
+			answer := &callbackAnswer{}
+			err := next(withFailureHook(withRequestHook(ctx, answer.observe), answer.fail), upd)
+			if answer.answered.Load() {
+				return err
+			}
+			opt := &OptAnswerCallbackQuery{}
+			if err != nil || answer.failed.Load() {
+				opt = &OptAnswerCallbackQuery{Text: alert, ShowAlert: true}
+			}
+			_, _ = AnswerCallbackQuery(ctx, upd.CallbackQuery.Id, opt)
+			return err
+		}
+	})
+}
+
+// observe is the request hook of the handled query, it marks the query answered on AnswerCallbackQuery.
+func (answer *callbackAnswer) observe(method string) {
+	if method == "answerCallbackQuery" {
+		answer.answered.Store(true)
+	}
+}
+
+func (answer *callbackAnswer) fail(error) {
+	answer.failed.Store(true)
+}
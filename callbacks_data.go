@@ -0,0 +1,114 @@
+package tg
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"sync"
+)
+
+// CallbackDataLimit is the limit of InlineKeyboardButton.CallbackData in bytes.
+const CallbackDataLimit = 64
+
+const (
+	callbackDataHeaderSize   = 8 // base64 of keyboard id (4 bytes) and button id (2 bytes).
+	callbackDataInline       = '.'
+	callbackDataStored       = '~'
+	callbackDataPayloadLimit = CallbackDataLimit - callbackDataHeaderSize - 1
+)
+
+// callbackData is the callback data of Keyboard buttons: "<header>.<data>" or "<header>~<id of data in storage>".
+type callbackData struct {
+	KeyboardId uint32
+	ButtonId   int
+	Data       string
+}
+
+func (data *callbackData) Encode(storage CallbackDataStorage) (string, error) {
+	header := make([]byte, 6)
+	binary.BigEndian.PutUint32(header, data.KeyboardId)
+	binary.BigEndian.PutUint16(header[4:], uint16(data.ButtonId))
+	encoded := base64.RawURLEncoding.EncodeToString(header)
+
+	if len(data.Data) <= callbackDataPayloadLimit {
+		return encoded + string(callbackDataInline) + data.Data, nil
+	}
+	if storage == nil {
+		return "", &ErrorCallbackDataTooLong{Data: data.Data, Size: len(data.Data), Limit: callbackDataPayloadLimit}
+	}
+	id := callbackDataId(data.Data)
+	if err := storage.Store(id, data.Data); err != nil {
+		return "", err
+	}
+	return encoded + string(callbackDataStored) + id, nil
+}
+
+func decodeCallbackDataHeader(raw string) (keyboardId uint32, buttonId int, ok bool) {
+	if len(raw) <= callbackDataHeaderSize {
+		return 0, 0, false
+	}
+	header, err := base64.RawURLEncoding.DecodeString(raw[:callbackDataHeaderSize])
+	if err != nil || len(header) != 6 {
+		return 0, 0, false
+	}
+	if marker := raw[callbackDataHeaderSize]; marker != callbackDataInline && marker != callbackDataStored {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint32(header), int(binary.BigEndian.Uint16(header[4:])), true
+}
+
+func decodeCallbackData(raw string, storage CallbackDataStorage) (*callbackData, error) {
+	keyboardId, buttonId, ok := decodeCallbackDataHeader(raw)
+	if !ok {
+		return nil, &Error{Description: "bad callback data: " + raw}
+	}
+	data := &callbackData{KeyboardId: keyboardId, ButtonId: buttonId, Data: raw[callbackDataHeaderSize+1:]}
+	if raw[callbackDataHeaderSize] == callbackDataInline {
+		return data, nil
+	}
+
+	if storage == nil {
+		return nil, &Error{Description: "callback data storage is not set, data id: " + data.Data}
+	}
+	stored, ok := storage.Load(data.Data)
+	if !ok {
+		return nil, &Error{Description: "callback data not found (expired?), data id: " + data.Data}
+	}
+	data.Data = stored
+	return data, nil
+}
+
+// callbackDataId is a short (16 characters) id of the data, equal data shares the same id.
+func callbackDataId(data string) string {
+	hash := sha256.Sum256([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(hash[:12])
+}
+
+// CallbackDataStorage keeps data of buttons, which does not fit into the callback data, by short ids.
+type CallbackDataStorage interface {
+	Load(id string) (data string, ok bool)
+	Store(id string, data string) error
+}
+
+var _ CallbackDataStorage = (*callbackDataStorageMemory)(nil)
+
+func NewCallbackDataStorageMemory() CallbackDataStorage {
+	return &callbackDataStorageMemory{}
+}
+
+type callbackDataStorageMemory struct {
+	data sync.Map
+}
+
+func (storage *callbackDataStorageMemory) Load(id string) (string, bool) {
+	data, ok := storage.data.Load(id)
+	if !ok {
+		return "", false
+	}
+	return data.(string), true
+}
+
+func (storage *callbackDataStorageMemory) Store(id string, data string) error {
+	storage.data.Store(id, data)
+	return nil
+}
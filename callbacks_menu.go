@@ -0,0 +1,285 @@
+package tg
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	menuButtonBack = 0xffff
+	menuButtonHome = 0xfffe
+)
+
+// Menu is a tree of screens rendered by editing one message: items open submenus or call handlers,
+// Back/Home buttons are added automatically. Every user has own navigation stack of the message (kept in memory
+// for TTL since the last press), so a submenu reachable from several menus returns where the user came from.
+// Dynamic items are requested on every render, their submenus work as the static ones.
+//
+// Example:
+//
+//	language := &tg.Menu{Id: "language", Text: "Pick a language", Items: []*tg.MenuItem{
+//		{Text: "English", Handler: setLanguage("en")},
+//		{Text: "Русский", Handler: setLanguage("ru")},
+//	}}
+//	settings := &tg.Menu{Id: "settings", Text: "Settings", Items: []*tg.MenuItem{
+//		{Text: "Language", Menu: language},
+//		{Text: "Notifications", Menu: notifications},
+//	}}
+//	bot.
+//		Menu(settings).
+//		Command("/settings", func(ctx context.Context, upd *tg.Update) error {
+//			_, err := settings.Open(ctx, upd)
+//			return err
+//		})
+type Menu struct {
+	// Id is unique among menus of the tree.
+	Id      string
+	Text    string
+	Items   []*MenuItem
+	Dynamic func(ctx context.Context, upd *Update) ([]*MenuItem, error)
+	// Columns of items, 1 by default.
+	Columns int
+	// Back and Home are labels of navigation buttons, only the root's ones are used.
+	Back string
+	Home string
+	// TTL of navigation stacks, a day by default (only the root's one is used). A forgotten stack is rebuilt
+	// as the path from the root to the screen.
+	TTL time.Duration
+
+	initOnce sync.Once
+	mutex    sync.Mutex
+	menus    map[string]*Menu
+	parents  map[string]string
+	stacks   map[string]*menuStack
+}
+
+type menuStack struct {
+	screens   []string
+	expiresAt time.Time
+}
+
+// MenuItem opens the Menu or calls the Handler, the current screen is re-rendered after the handler.
+type MenuItem struct {
+	Text    string
+	Menu    *Menu
+	Handler HandlerFunc
+}
+
+// Menu handles callbacks of the menu (and its submenus) with a single branch.
+func (bot *Bot) Menu(menu *Menu) *Bot {
+	menu.init()
+	return bot.Branch(menu.FilterFunc(), menu.HandlerFunc())
+}
+
+// Open sends the root screen of the menu to the chat of the update.
+func (m *Menu) Open(ctx context.Context, upd *Update, opts ...*OptSendMessage) (*Message, error) {
+	m.init()
+	text, markup, _, err := m.render(ctx, upd, m, 1)
+	if err != nil {
+		return nil, err
+	}
+	opt := optsMerge(opts)
+	opt.ReplyMarkup = markup
+	message, err := SendMessage(ctx, getChatId(upd), text, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	m.remember(menuStackKey(message.Chat.Id, message.MessageId, getSenderId(upd)), []string{m.Id})
+	return message, nil
+}
+
+func (m *Menu) FilterFunc() FilterFunc {
+	return All(OnCallback, func(ctx context.Context, upd *Update) bool {
+		keyboardId, _, ok := decodeCallbackDataHeader(upd.CallbackQuery.Data)
+		return ok && keyboardId == m.keyboardId()
+	})
+}
+
+func (m *Menu) HandlerFunc() HandlerFunc {
+	return func(ctx context.Context, upd *Update) error {
+		m.init()
+		query := upd.CallbackQuery
+		pressed, err := decodeCallbackData(query.Data, nil)
+		if err != nil {
+			return err
+		}
+		key := menuStackKey(getChatIdOfMessage(query.Message), getMessageId(query.Message), getSenderId(upd))
+		if query.Message == nil {
+			key = fmt.Sprintf("%s:%d", query.InlineMessageId, getSenderId(upd))
+		}
+
+		stack := m.stack(key, pressed.Data)
+		if len(stack) == 0 {
+			return &Error{Description: "tg.Menu: unknown screen " + pressed.Data}
+		}
+		screen := m.lookup(stack[len(stack)-1])
+		textBefore, markupBefore, items, err := m.render(ctx, upd, screen, len(stack))
+		if err != nil {
+			return err
+		}
+
+		var item *MenuItem
+		if pressed.ButtonId > 0 {
+			item = at(items, pressed.ButtonId-1, nil)
+		}
+		switch {
+		case pressed.ButtonId == menuButtonBack && len(stack) > 1:
+			stack = stack[:len(stack)-1]
+		case pressed.ButtonId == menuButtonHome:
+			stack = stack[:1]
+		case item != nil && item.Menu != nil:
+			stack = append(stack, item.Menu.Id)
+		case item != nil && item.Handler != nil:
+			if err = item.Handler(ctx, upd); err != nil {
+				return err
+			}
+		}
+
+		m.remember(key, stack)
+
+		text, markup, _, err := m.render(ctx, upd, m.lookup(stack[len(stack)-1]), len(stack))
+		if err != nil || (text == textBefore && widgetMarkupEqual(markup, markupBefore)) {
+			return err
+		}
+		_, err = EditMessageText(ctx, text, &OptEditMessageText{
+			ChatId:          getChatIdOfMessage(query.Message),
+			MessageId:       getMessageId(query.Message),
+			InlineMessageId: query.InlineMessageId,
+			ReplyMarkup:     markup,
+		})
+		return err
+	}
+}
+
+func (m *Menu) init() {
+	m.initOnce.Do(func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		m.menus, m.parents, m.stacks = map[string]*Menu{}, map[string]string{}, map[string]*menuStack{}
+		m.index(m, "")
+	})
+}
+
+// index adds the menu and its static submenus to the tree, must be called under the mutex.
+func (m *Menu) index(menu *Menu, parent string) {
+	if _, ok := m.menus[menu.Id]; ok {
+		return
+	}
+	m.menus[menu.Id] = menu
+	if parent != "" {
+		m.parents[menu.Id] = parent
+	}
+	for _, item := range menu.Items {
+		if item.Menu != nil {
+			m.index(item.Menu, menu.Id)
+		}
+	}
+}
+
+func (m *Menu) lookup(id string) *Menu {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.menus[id]
+}
+
+// stack returns the navigation stack ending with the screen, the path from the root is used for other users/restarts.
+func (m *Menu) stack(key string, screen string) []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if stack, ok := m.stacks[key]; ok && time.Now().Before(stack.expiresAt) && stack.screens[len(stack.screens)-1] == screen {
+		return slices.Clone(stack.screens)
+	}
+	if _, ok := m.menus[screen]; !ok {
+		return nil
+	}
+	path := []string{screen}
+	for parent, ok := m.parents[screen]; ok; parent, ok = m.parents[parent] {
+		path = append(path, parent)
+	}
+	slices.Reverse(path)
+	return path
+}
+
+// remember saves the navigation stack and forgets expired ones.
+func (m *Menu) remember(key string, screens []string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	for key, stack := range m.stacks {
+		if !now.Before(stack.expiresAt) {
+			delete(m.stacks, key)
+		}
+	}
+	m.stacks[key] = &menuStack{screens: screens, expiresAt: now.Add(cmp.Or(m.TTL, 24*time.Hour))}
+}
+
+func (m *Menu) render(ctx context.Context, upd *Update, screen *Menu, depth int) (string, *InlineKeyboardMarkup, []*MenuItem, error) {
+	items := slices.Clone(screen.Items)
+	if screen.Dynamic != nil {
+		dynamic, err := screen.Dynamic(ctx, upd)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		items = append(items, dynamic...)
+		m.mutex.Lock()
+		for _, item := range dynamic {
+			if item.Menu != nil {
+				m.index(item.Menu, screen.Id)
+			}
+		}
+		m.mutex.Unlock()
+	}
+
+	columns := cmp.Or(screen.Columns, 1)
+	layout := [][]*InlineKeyboardButton{}
+	button := func(text string, buttonId int) (*InlineKeyboardButton, error) {
+		data, err := (&callbackData{KeyboardId: m.keyboardId(), ButtonId: buttonId, Data: screen.Id}).Encode(nil)
+		return &InlineKeyboardButton{Text: text, CallbackData: data}, err
+	}
+	for i, item := range items {
+		if i%columns == 0 {
+			layout = append(layout, []*InlineKeyboardButton{})
+		}
+		built, err := button(item.Text, i+1)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		layout[len(layout)-1] = append(layout[len(layout)-1], built)
+	}
+
+	navigation := []*InlineKeyboardButton{}
+	if depth > 1 {
+		back, err := button(cmp.Or(m.Back, "« Back"), menuButtonBack)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		navigation = append(navigation, back)
+	}
+	if depth > 2 {
+		home, err := button(cmp.Or(m.Home, "⌂ Home"), menuButtonHome)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		navigation = append(navigation, home)
+	}
+	if len(navigation) > 0 {
+		layout = append(layout, navigation)
+	}
+	return screen.Text, &InlineKeyboardMarkup{InlineKeyboard: layout}, items, nil
+}
+
+func (m *Menu) keyboardId() uint32 {
+	hash := fnv.New32()
+	_, _ = hash.Write([]byte("menu:" + m.Id))
+	return hash.Sum32()
+}
+
+func menuStackKey(chatId int64, messageId int64, userId int64) string {
+	return fmt.Sprintf("%d:%d:%d", chatId, messageId, userId)
+}
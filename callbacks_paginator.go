@@ -0,0 +1,223 @@
+package tg
+
+import (
+	"cmp"
+	"context"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PageSource returns items of the page and the total number of items.
+type PageSource[T any] func(ctx context.Context, offset int, limit int) (items []T, total int, err error)
+
+const (
+	paginatorButtonPage    = 0xffff
+	paginatorButtonCurrent = 0xfffe
+)
+
+// Paginator is an inline keyboard of CallbackButton-s of items page by page with «/» navigation, the page is switched
+// by editing the original message. All pages are served by a single keyboard registered in the bot
+// (see Bot.RegisterKeyboard), the page number is kept in the callback data. A pressed item is looked up in its page
+// again, so buttons' Data must fit into the callback data with the page number. Id must be unique among paginators
+// of the bot, add Branch to the bot, so messages sent before a restart work before the paginator builds a page.
+// InlineResults serves the same source to inline queries with NextOffset.
+//
+// Example:
+//
+//	files := &tg.Paginator[string]{
+//		Id:       "files",
+//		PageSize: 5,
+//		Source: func(ctx context.Context, offset int, limit int) ([]string, int, error) {
+//			return names[min(offset, len(names)):min(offset+limit, len(names))], len(names), nil
+//		},
+//		Button: func(name string) *tg.CallbackButton {
+//			return &tg.CallbackButton{Text: name, Data: name, Handler: tg.CommonTextReply("you picked " + name)}
+//		},
+//	}
+//	bot.
+//		Branch(files.Branch()).
+//		Command("/files", func(ctx context.Context, upd *tg.Update) error {
+//			_, err := files.Send(ctx, upd.Message.Chat.Id, "your files")
+//			return err
+//		})
+type Paginator[T any] struct {
+	Id string
+	// PageSize is 10 by default.
+	PageSize int
+	// Columns of items, 1 by default.
+	Columns int
+	Source  PageSource[T]
+	Button  func(item T) *CallbackButton
+	// TTL makes Bot forget the paginator if no page was built for that long, zero keeps it registered forever.
+	TTL time.Duration
+}
+
+// Send sends the message with the first page.
+func (p *Paginator[T]) Send(ctx context.Context, chatId int64, text string, opts ...*OptSendMessage) (*Message, error) {
+	markup, err := p.Markup(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	opt := optsMerge(opts)
+	opt.ReplyMarkup = markup
+	return SendMessage(ctx, chatId, text, opt)
+}
+
+// Markup builds the keyboard of the page (starting with 0) and registers the paginator in the bot.
+func (p *Paginator[T]) Markup(ctx context.Context, page int) (*InlineKeyboardMarkup, error) {
+	bot, ok := ctx.Value(ContextBotInstance).(*Bot)
+	if !ok {
+		return nil, &Error{Description: "tg.Paginator: bot not found in context"}
+	}
+	markup, _, err := p.build(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+	bot.registerCallbacks(p.keyboardId(), p.handle, p.TTL)
+	return markup, nil
+}
+
+func (p *Paginator[T]) build(ctx context.Context, page int) (*InlineKeyboardMarkup, []T, error) {
+	pageSize, columns := cmp.Or(p.PageSize, 10), cmp.Or(p.Columns, 1)
+	items, total, err := p.Source(ctx, page*pageSize, pageSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	pages := max((total+pageSize-1)/pageSize, 1)
+
+	button := func(text string, buttonId int, data string) (*InlineKeyboardButton, error) {
+		encoded, err := (&callbackData{KeyboardId: p.keyboardId(), ButtonId: buttonId, Data: strconv.Itoa(page) + ":" + data}).Encode(nil)
+		return &InlineKeyboardButton{Text: text, CallbackData: encoded}, err
+	}
+	layout := [][]*InlineKeyboardButton{}
+	for i, item := range items {
+		if i%columns == 0 {
+			layout = append(layout, []*InlineKeyboardButton{})
+		}
+		built := p.Button(item)
+		itemButton, err := button(built.Text, i+1, built.Data)
+		if err != nil {
+			return nil, nil, err
+		}
+		layout[len(layout)-1] = append(layout[len(layout)-1], itemButton)
+	}
+	if pages > 1 {
+		navigation := []*InlineKeyboardButton{}
+		if page > 0 {
+			previous, err := button("«", paginatorButtonPage, strconv.Itoa(page-1))
+			if err != nil {
+				return nil, nil, err
+			}
+			navigation = append(navigation, previous)
+		}
+		current, err := button(strconv.Itoa(page+1)+"/"+strconv.Itoa(pages), paginatorButtonCurrent, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		navigation = append(navigation, current)
+		if page+1 < pages {
+			next, err := button("»", paginatorButtonPage, strconv.Itoa(page+1))
+			if err != nil {
+				return nil, nil, err
+			}
+			navigation = append(navigation, next)
+		}
+		layout = append(layout, navigation)
+	}
+	return &InlineKeyboardMarkup{InlineKeyboard: layout}, items, nil
+}
+
+func (p *Paginator[T]) Branch() (FilterFunc, HandlerFunc) {
+	return p.FilterFunc(), p.HandlerFunc()
+}
+
+func (p *Paginator[T]) FilterFunc() FilterFunc {
+	return All(OnCallback, func(ctx context.Context, upd *Update) bool {
+		keyboardId, _, ok := decodeCallbackDataHeader(upd.CallbackQuery.Data)
+		return ok && keyboardId == p.keyboardId()
+	})
+}
+
+func (p *Paginator[T]) HandlerFunc() HandlerFunc {
+	return p.handle
+}
+
+// handle serves callbacks of all pages: navigation edits the message, items call handlers of their buttons.
+func (p *Paginator[T]) handle(ctx context.Context, upd *Update) error {
+	query := upd.CallbackQuery
+	pressed, err := decodeCallbackData(query.Data, nil)
+	if err != nil {
+		return err
+	}
+	pageText, data, _ := strings.Cut(pressed.Data, ":")
+	page, err := strconv.Atoi(pageText)
+	if err != nil {
+		return &Error{Description: "tg.Paginator: bad page in callback data " + pressed.Data}
+	}
+
+	switch pressed.ButtonId {
+	case paginatorButtonCurrent:
+		return nil
+	case paginatorButtonPage:
+		if page, err = strconv.Atoi(data); err != nil {
+			return &Error{Description: "tg.Paginator: bad page in callback data " + pressed.Data}
+		}
+	default:
+		_, items, err := p.build(ctx, page)
+		if err != nil {
+			return err
+		}
+		// The item is pressed only if the page still has it at the same place, otherwise the page is just refreshed.
+		if pressed.ButtonId >= 1 && pressed.ButtonId <= len(items) {
+			if button := p.Button(items[pressed.ButtonId-1]); button.Data == data {
+				query.Data = data
+				return button.HandlerFunc()(ctx, upd)
+			}
+		}
+	}
+
+	markup, err := p.Markup(ctx, page)
+	if err != nil {
+		return err
+	}
+	_, err = EditMessageReplyMarkup(ctx, &OptEditMessageReplyMarkup{
+		ChatId:          getChatIdOfMessage(query.Message),
+		MessageId:       getMessageId(query.Message),
+		InlineMessageId: query.InlineMessageId,
+		ReplyMarkup:     markup,
+	})
+	return err
+}
+
+func (p *Paginator[T]) keyboardId() uint32 {
+	hash := fnv.New32()
+	_, _ = hash.Write([]byte("paginator:" + p.Id))
+	return hash.Sum32()
+}
+
+// InlineResults answers the inline query of the update with the page at its offset, NextOffset points to the next page.
+// Telegram shows up to 50 results per page.
+func (p *Paginator[T]) InlineResults(ctx context.Context, upd *Update, result func(item T) InlineQueryResult, opts ...*OptAnswerInlineQuery) error {
+	if upd == nil || upd.InlineQuery == nil {
+		return &Error{Description: "tg.Paginator: not an inline query"}
+	}
+	offset, _ := strconv.Atoi(upd.InlineQuery.Offset)
+	limit := min(cmp.Or(p.PageSize, 10), 50)
+	items, total, err := p.Source(ctx, offset, limit)
+	if err != nil {
+		return err
+	}
+
+	results := make([]InlineQueryResult, len(items))
+	for i, item := range items {
+		results[i] = result(item)
+	}
+	opt := optsMerge(opts)
+	if next := offset + len(items); len(items) > 0 && next < total {
+		opt.NextOffset = strconv.Itoa(next)
+	}
+	_, err = AnswerInlineQuery(ctx, upd.InlineQuery.Id, results, opt)
+	return err
+}
@@ -0,0 +1,239 @@
+package tg
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+)
+
+// ReplyButtonI is a button of ReplyKeyboard, which handles the message sent by pressing it.
+type ReplyButtonI interface {
+	Build() *KeyboardButton
+	FilterFunc() FilterFunc
+	HandlerFunc() HandlerFunc
+}
+
+var (
+	_ ReplyButtonI = (*ReplyButton)(nil)
+	_ ReplyButtonI = (*ContactButton)(nil)
+	_ ReplyButtonI = (*LocationButton)(nil)
+	_ ReplyButtonI = (*UsersButton)(nil)
+	_ ReplyButtonI = (*ChatButton)(nil)
+)
+
+// ReplyKeyboard is a ReplyKeyboardMarkup with handlers of its buttons.
+//
+// Example:
+//
+//	keyboard := &tg.ReplyKeyboard{
+//		Layout: [][]tg.ReplyButtonI{
+//			{&tg.ReplyButton{Text: "Menu", Handler: menu}, &tg.ReplyButton{Text: "Help", Handler: help}},
+//			{&tg.ContactButton{Text: "Share phone", Handler: func(ctx context.Context, upd *tg.Update, contact *tg.Contact) error {
+//				...
+//			}}},
+//		},
+//		Resize: true,
+//	}
+//	bot.
+//		Branch(keyboard.Branch()).
+//		Command("/start", func(ctx context.Context, upd *tg.Update) error {
+//			_, err := tg.SendMessage(ctx, upd.Message.Chat.Id, "hi", &tg.OptSendMessage{ReplyMarkup: keyboard.Build()})
+//			return err
+//		})
+type ReplyKeyboard struct {
+	Layout      [][]ReplyButtonI
+	Persistent  bool
+	Resize      bool
+	OneTime     bool
+	Placeholder string
+	Selective   bool
+}
+
+func (k *ReplyKeyboard) Build() *ReplyKeyboardMarkup {
+	result := make([][]*KeyboardButton, len(k.Layout))
+	for i, buttons := range k.Layout {
+		result[i] = make([]*KeyboardButton, len(buttons))
+		for j, button := range buttons {
+			result[i][j] = button.Build()
+		}
+	}
+	return &ReplyKeyboardMarkup{
+		Keyboard:              result,
+		IsPersistent:          k.Persistent,
+		ResizeKeyboard:        k.Resize,
+		OneTimeKeyboard:       k.OneTime,
+		InputFieldPlaceholder: k.Placeholder,
+		Selective:             k.Selective,
+	}
+}
+
+func (k *ReplyKeyboard) Branch() (FilterFunc, HandlerFunc) {
+	return k.FilterFunc(), k.HandlerFunc()
+}
+
+// FilterFunc matches messages sent by any button of the keyboard.
+func (k *ReplyKeyboard) FilterFunc() FilterFunc {
+	return func(ctx context.Context, upd *Update) bool {
+		return k.button(ctx, upd) != nil
+	}
+}
+
+// HandlerFunc calls the handler of the first matching button.
+func (k *ReplyKeyboard) HandlerFunc() HandlerFunc {
+	return func(ctx context.Context, upd *Update) error {
+		button := k.button(ctx, upd)
+		if button == nil {
+			slog.Warn("tg.ReplyKeyboard#no_button", "update_id", upd.UpdateId)
+			return nil
+		}
+		return button.HandlerFunc()(ctx, upd)
+	}
+}
+
+func (k *ReplyKeyboard) button(ctx context.Context, upd *Update) ReplyButtonI {
+	if !OnMessage(ctx, upd) {
+		return nil
+	}
+	for _, buttons := range k.Layout {
+		for _, button := range buttons {
+			if filterWrappedPanics(button.FilterFunc(), ctx, upd) {
+				return button
+			}
+		}
+	}
+	return nil
+}
+
+// ReplyButton handles messages with exactly the text of the button.
+type ReplyButton struct {
+	Text    string
+	Handler HandlerFunc
+}
+
+func (b *ReplyButton) Build() *KeyboardButton { return &KeyboardButton{Text: b.Text} }
+
+func (b *ReplyButton) FilterFunc() FilterFunc {
+	return func(ctx context.Context, upd *Update) bool {
+		return upd.Message.Text == b.Text
+	}
+}
+
+func (b *ReplyButton) HandlerFunc() HandlerFunc { return b.Handler }
+
+// ContactButton requests the phone number of the user, other users' contacts are not handled.
+type ContactButton struct {
+	Text    string
+	Handler CommonArgsHandlerFunc[*Contact]
+}
+
+func (b *ContactButton) Build() *KeyboardButton {
+	return &KeyboardButton{Text: b.Text, RequestContact: true}
+}
+
+func (b *ContactButton) FilterFunc() FilterFunc {
+	return func(ctx context.Context, upd *Update) bool {
+		contact := upd.Message.Contact
+		return contact != nil && upd.Message.From != nil && contact.UserId == upd.Message.From.Id
+	}
+}
+
+func (b *ContactButton) HandlerFunc() HandlerFunc {
+	return func(ctx context.Context, upd *Update) error {
+		return b.Handler(ctx, upd, upd.Message.Contact)
+	}
+}
+
+// LocationButton requests the current location of the user.
+type LocationButton struct {
+	Text    string
+	Handler CommonArgsHandlerFunc[*Location]
+}
+
+func (b *LocationButton) Build() *KeyboardButton {
+	return &KeyboardButton{Text: b.Text, RequestLocation: true}
+}
+
+func (b *LocationButton) FilterFunc() FilterFunc {
+	return func(ctx context.Context, upd *Update) bool {
+		return upd.Message.Location != nil && upd.Message.Venue == nil
+	}
+}
+
+func (b *LocationButton) HandlerFunc() HandlerFunc {
+	return func(ctx context.Context, upd *Update) error {
+		return b.Handler(ctx, upd, upd.Message.Location)
+	}
+}
+
+// UsersButton requests users by Request (any user by default), the request_id is derived from Text if not set.
+type UsersButton struct {
+	Text    string
+	Request *KeyboardButtonRequestUsers
+	Handler CommonArgsHandlerFunc[*UsersShared]
+}
+
+func (b *UsersButton) Build() *KeyboardButton {
+	request := deref(b.Request)
+	request.RequestId = b.requestId()
+	return &KeyboardButton{Text: b.Text, RequestUsers: &request}
+}
+
+func (b *UsersButton) FilterFunc() FilterFunc {
+	return func(ctx context.Context, upd *Update) bool {
+		shared := upd.Message.UsersShared
+		return shared != nil && shared.RequestId == b.requestId()
+	}
+}
+
+func (b *UsersButton) HandlerFunc() HandlerFunc {
+	return func(ctx context.Context, upd *Update) error {
+		return b.Handler(ctx, upd, upd.Message.UsersShared)
+	}
+}
+
+func (b *UsersButton) requestId() int64 {
+	if b.Request != nil && b.Request.RequestId != 0 {
+		return b.Request.RequestId
+	}
+	return replyButtonRequestId("users:" + b.Text)
+}
+
+// ChatButton requests a chat by Request (any group by default), the request_id is derived from Text if not set.
+type ChatButton struct {
+	Text    string
+	Request *KeyboardButtonRequestChat
+	Handler CommonArgsHandlerFunc[*ChatShared]
+}
+
+func (b *ChatButton) Build() *KeyboardButton {
+	request := deref(b.Request)
+	request.RequestId = b.requestId()
+	return &KeyboardButton{Text: b.Text, RequestChat: &request}
+}
+
+func (b *ChatButton) FilterFunc() FilterFunc {
+	return func(ctx context.Context, upd *Update) bool {
+		shared := upd.Message.ChatShared
+		return shared != nil && shared.RequestId == b.requestId()
+	}
+}
+
+func (b *ChatButton) HandlerFunc() HandlerFunc {
+	return func(ctx context.Context, upd *Update) error {
+		return b.Handler(ctx, upd, upd.Message.ChatShared)
+	}
+}
+
+func (b *ChatButton) requestId() int64 {
+	if b.Request != nil && b.Request.RequestId != 0 {
+		return b.Request.RequestId
+	}
+	return replyButtonRequestId("chat:" + b.Text)
+}
+
+// replyButtonRequestId is a positive signed 32-bit request_id.
+func replyButtonRequestId(key string) int64 {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	return int64(hash.Sum32()&0x7fffffff) | 1
+}
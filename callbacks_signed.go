@@ -0,0 +1,155 @@
+package tg
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+)
+
+const (
+	callbackSignatureSize     = 16 // base64 of expiry (4 bytes, unix seconds) and truncated HMAC-SHA256 (8 bytes).
+	callbackSignatureSplit    = ':'
+	contextCallbackVerified   = contextPrefix + "callback_verified"
+	defaultCallbackAlert      = "This button is no longer valid."
+	callbackSignedPayloadSize = CallbackDataLimit - callbackSignatureSize - 1
+)
+
+// CallbackSigner signs callback data with HMAC of the bot's secret and embeds the expiry,
+// the signed data is "<signature>:<payload>", so up to 47 bytes are left for the payload.
+type CallbackSigner struct {
+	secret []byte
+	ttl    time.Duration
+	alert  string
+}
+
+// NewCallbackSigner signs callback data, which expires after ttl with seconds precision (never if zero).
+func NewCallbackSigner(secret []byte, ttl time.Duration) *CallbackSigner {
+	return &CallbackSigner{secret: secret, ttl: ttl, alert: defaultCallbackAlert}
+}
+
+// Alert is shown to the user, who pressed a button with invalid or expired data.
+func (signer *CallbackSigner) Alert(text string) *CallbackSigner {
+	signer.alert = text
+	return signer
+}
+
+// CallbackSigner makes the bot accept only signed callback data (besides data of keyboards). Callbacks with unsigned,
+// tampered or expired data are answered with the alert and reported to OnError as ErrorCallbackDataInvalid,
+// valid ones reach handlers with the payload only, so CallbackData works as usual.
+//
+// Example:
+//
+//	bot.
+//		CallbackSigner(tg.NewCallbackSigner([]byte(os.Getenv("SECRET")), time.Hour)).
+//		Command("/vote", func(ctx context.Context, upd *tg.Update) error {
+//			data, err := tg.SignCallbackData(ctx, &Vote{Option: 1}) // -> InlineKeyboardButton.CallbackData
+//			...
+//		}).
+//		Branch(tg.OnCallbackWithData[Vote](), func(ctx context.Context, upd *tg.Update) error {
+//			vote, err := tg.CallbackData[Vote](upd) // Signed by the bot and not expired.
+//			...
+//		})
+func (bot *Bot) CallbackSigner(signer *CallbackSigner) *Bot {
+	bot.context = context.WithValue(bot.context, ContextCallbackSigner, signer)
+	return bot.Use(signer.Middleware)
+}
+
+// SignCallbackData signs json of the value with the CallbackSigner of the bot.
+func SignCallbackData(ctx context.Context, value any) (string, error) {
+	signer, ok := ctx.Value(ContextCallbackSigner).(*CallbackSigner)
+	if !ok {
+		return "", &Error{Description: "callback signer not found in context, use Bot.CallbackSigner"}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return signer.Sign(string(data))
+}
+
+// Sign signs the payload, ErrorCallbackDataTooLong is returned if the signed data does not fit into the callback data.
+func (signer *CallbackSigner) Sign(payload string) (string, error) {
+	if len(payload) > callbackSignedPayloadSize {
+		return "", &ErrorCallbackDataTooLong{Data: payload, Size: len(payload), Limit: callbackSignedPayloadSize}
+	}
+	var expiresAt uint32
+	if signer.ttl > 0 {
+		expiresAt = uint32(time.Now().Add(signer.ttl).Unix())
+	}
+	return base64.RawURLEncoding.EncodeToString(signer.signature(expiresAt, payload)) + string(callbackSignatureSplit) + payload, nil
+}
+
+// Verify returns the payload of the signed data.
+func (signer *CallbackSigner) Verify(data string) (string, error) {
+	if !callbackDataSigned(data) {
+		return "", &ErrorCallbackDataInvalid{Data: data, Reason: "not signed"}
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(data[:callbackSignatureSize])
+	if err != nil {
+		return "", &ErrorCallbackDataInvalid{Data: data, Reason: "bad signature"}
+	}
+	payload := data[callbackSignatureSize+1:]
+	expiresAt := binary.BigEndian.Uint32(signature)
+	if !hmac.Equal(signature, signer.signature(expiresAt, payload)) {
+		return "", &ErrorCallbackDataInvalid{Data: data, Reason: "bad signature"}
+	}
+	if expiresAt != 0 && time.Now().Unix() >= int64(expiresAt) {
+		return "", &ErrorCallbackDataInvalid{Data: data, Reason: "expired", Expired: true}
+	}
+	return payload, nil
+}
+
+// Middleware verifies callback data: the valid payload replaces CallbackQuery.Data, the invalid or unsigned one
+// is answered with the alert and the update is not handled. Data of keyboards (Keyboard, Widget, Menu, Paginator)
+// is passed as is, they check it themselves.
+func (signer *CallbackSigner) Middleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, upd *Update) error {
+		if !OnCallback(ctx, upd) || upd.CallbackQuery.Data == "" {
+			return next(ctx, upd)
+		}
+		if _, _, ok := decodeCallbackDataHeader(upd.CallbackQuery.Data); ok {
+			return next(ctx, upd)
+		}
+		payload, err := signer.Verify(upd.CallbackQuery.Data)
+		if err != nil {
+			_, _ = AnswerCallbackQuery(ctx, upd.CallbackQuery.Id, &OptAnswerCallbackQuery{Text: signer.alert, ShowAlert: true})
+			return err
+		}
+		upd.CallbackQuery.Data = payload
+		return next(context.WithValue(ctx, contextCallbackVerified, true), upd)
+	}
+}
+
+func (signer *CallbackSigner) signature(expiresAt uint32, payload string) []byte {
+	result := binary.BigEndian.AppendUint32(nil, expiresAt)
+	mac := hmac.New(sha256.New, signer.secret)
+	_, _ = mac.Write(result)
+	_, _ = mac.Write([]byte(payload))
+	return mac.Sum(result)[:12]
+}
+
+// callbackDataSigned checks the format only: base64 signature and ":" (never a prefix of Keyboard's data or json).
+func callbackDataSigned(data string) bool {
+	if len(data) <= callbackSignatureSize || data[callbackSignatureSize] != callbackSignatureSplit {
+		return false
+	}
+	for _, char := range data[:callbackSignatureSize] {
+		if !('a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || '0' <= char && char <= '9' || char == '-' || char == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// callbackDataVerified is false only if the bot signs callback data, but the update's data was not verified.
+func callbackDataVerified(ctx context.Context) bool {
+	if _, ok := ctx.Value(ContextCallbackSigner).(*CallbackSigner); !ok {
+		return true
+	}
+	verified, _ := ctx.Value(contextCallbackVerified).(bool)
+	return verified
+}
@@ -0,0 +1,358 @@
+package tg
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Widget is an inline keyboard rendered from the state S of its message: buttons change the state
+// and the keyboard is re-rendered in place with EditMessageReplyMarkup. States are kept per message in the Storage
+// (in memory by default) for TTL since the last press, Id must be unique among widgets of the bot.
+// Forget drops the state of a message right away, e.g. when the order is placed.
+//
+// Example:
+//
+//	type Order struct {
+//		Size   string   `json:"size"`
+//		Extras []string `json:"extras"`
+//		Count  int      `json:"count"`
+//		Gift   bool     `json:"gift"`
+//	}
+//	order := &tg.Widget[Order]{
+//		Id: "order",
+//		Render: func(ctx context.Context, order *Order) [][]*tg.WidgetButton {
+//			return [][]*tg.WidgetButton{
+//				tg.Radio(&order.Size, []string{"S", "M", "L"}, nil),
+//				tg.MultiSelect(&order.Extras, []string{"milk", "syrup"}, nil),
+//				tg.Counter(&order.Count, 1, 10),
+//				{tg.Toggle("gift", &order.Gift)},
+//			}
+//		},
+//	}
+//	bot.
+//		Branch(order.Branch()).
+//		Command("/order", func(ctx context.Context, upd *tg.Update) error {
+//			_, err := order.Send(ctx, upd.Message.Chat.Id, "your order", &Order{Size: "M", Count: 1})
+//			return err
+//		})
+type Widget[S any] struct {
+	Id      string
+	Render  func(ctx context.Context, state *S) [][]*WidgetButton
+	Storage SessionStorage
+	// TTL of states of messages, a day by default. Expiry is tracked in memory, so states stored before a restart
+	// live until their next press plus TTL.
+	TTL time.Duration
+
+	storageOnce sync.Once
+	mutex       sync.Mutex
+	expiries    map[string]time.Time
+}
+
+// WidgetButton changes the state rendered by Widget.Render with OnPress (no-op if nil), so OnPress captures
+// the state (or its fields) of Render. Payload is sent within the callback data as json, OnPress gets it
+// as CallbackQuery.Data (see CallbackData).
+type WidgetButton struct {
+	Text    string
+	Payload any
+	OnPress HandlerFunc
+}
+
+// Send sends the message with the widget rendered from the initial state.
+func (w *Widget[S]) Send(ctx context.Context, chatId int64, text string, state *S, opts ...*OptSendMessage) (*Message, error) {
+	markup, _, err := w.build(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	opt := optsMerge(opts)
+	opt.ReplyMarkup = markup
+	message, err := SendMessage(ctx, chatId, text, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	key := w.key(chatId, message.MessageId, "")
+	if err = w.touch(key); err != nil {
+		return nil, err
+	}
+	return message, w.storage().Store(key, data, 0)
+}
+
+// Forget deletes the state of the widget's message, its buttons stop working.
+func (w *Widget[S]) Forget(chatId int64, messageId int64) error {
+	key := w.key(chatId, messageId, "")
+	w.mutex.Lock()
+	delete(w.expiries, key)
+	w.mutex.Unlock()
+	return w.storage().Delete(key)
+}
+
+func (w *Widget[S]) Branch() (FilterFunc, HandlerFunc) {
+	return w.FilterFunc(), w.HandlerFunc()
+}
+
+func (w *Widget[S]) FilterFunc() FilterFunc {
+	return All(OnCallback, func(ctx context.Context, upd *Update) bool {
+		keyboardId, _, ok := decodeCallbackDataHeader(upd.CallbackQuery.Data)
+		return ok && keyboardId == w.keyboardId()
+	})
+}
+
+func (w *Widget[S]) HandlerFunc() HandlerFunc {
+	return func(ctx context.Context, upd *Update) error {
+		query := upd.CallbackQuery
+		pressed, err := decodeCallbackData(query.Data, nil)
+		if err != nil {
+			return err
+		}
+		var key string
+		if query.Message != nil {
+			key = w.key(query.Message.Chat.Id, query.Message.MessageId, "")
+		} else {
+			key = w.key(0, 0, query.InlineMessageId)
+		}
+
+		if err = w.touch(key); err != nil {
+			return err
+		}
+		data, version, err := w.storage().Load(key)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			return &Error{Description: "widget state not found: " + key}
+		}
+		state := new(S)
+		if err = json.Unmarshal(data, state); err != nil {
+			return fmt.Errorf("widget: bad json of '%s' (%w)", key, err)
+		}
+
+		before, buttons, err := w.build(ctx, state)
+		if err != nil {
+			return err
+		}
+		// The button is pressed only if the keyboard was not re-rendered meanwhile, the outdated keyboard is just replaced.
+		if button := at(slices.Concat(buttons...), pressed.ButtonId-1, nil); button != nil && button.OnPress != nil {
+			if payload, _ := widgetPayload(button.Payload); payload == pressed.Data {
+				query.Data = pressed.Data
+				if err = button.OnPress(ctx, upd); err != nil {
+					return err
+				}
+			}
+		}
+
+		after, _, err := w.build(ctx, state)
+		if err != nil {
+			return err
+		}
+		updated, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(data, updated) {
+			if err = w.storage().Store(key, updated, version); err != nil {
+				return err
+			}
+		}
+		if !widgetMarkupEqual(before, after) {
+			_, err = EditMessageReplyMarkup(ctx, &OptEditMessageReplyMarkup{
+				ChatId:          getChatIdOfMessage(query.Message),
+				MessageId:       getMessageId(query.Message),
+				InlineMessageId: query.InlineMessageId,
+				ReplyMarkup:     after,
+			})
+		}
+		return err
+	}
+}
+
+func (w *Widget[S]) build(ctx context.Context, state *S) (*InlineKeyboardMarkup, [][]*WidgetButton, error) {
+	buttons := w.Render(ctx, state)
+	result := make([][]*InlineKeyboardButton, len(buttons))
+	buttonId := 0
+	for i, row := range buttons {
+		result[i] = make([]*InlineKeyboardButton, len(row))
+		for j, button := range row {
+			buttonId++
+			payload, err := widgetPayload(button.Payload)
+			if err != nil {
+				return nil, nil, err
+			}
+			data, err := (&callbackData{KeyboardId: w.keyboardId(), ButtonId: buttonId, Data: payload}).Encode(nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			result[i][j] = &InlineKeyboardButton{Text: button.Text, CallbackData: data}
+		}
+	}
+	return &InlineKeyboardMarkup{InlineKeyboard: result}, buttons, nil
+}
+
+func (w *Widget[S]) keyboardId() uint32 {
+	hash := fnv.New32()
+	_, _ = hash.Write([]byte("widget:" + w.Id))
+	return hash.Sum32()
+}
+
+func (w *Widget[S]) key(chatId int64, messageId int64, inlineMessageId string) string {
+	if inlineMessageId != "" {
+		return "widget:" + w.Id + ":" + inlineMessageId
+	}
+	return fmt.Sprintf("widget:%s:%d:%d", w.Id, chatId, messageId)
+}
+
+// touch prolongs the state of the key and deletes expired states, the key is deleted too if it has expired.
+func (w *Widget[S]) touch(key string) error {
+	w.mutex.Lock()
+	if w.expiries == nil {
+		w.expiries = map[string]time.Time{}
+	}
+	now, expired := time.Now(), []string{}
+	for key, expiresAt := range w.expiries {
+		if !now.Before(expiresAt) {
+			expired = append(expired, key)
+			delete(w.expiries, key)
+		}
+	}
+	w.expiries[key] = now.Add(cmp.Or(w.TTL, 24*time.Hour))
+	w.mutex.Unlock()
+
+	errs := []error{}
+	for _, key := range expired {
+		errs = append(errs, w.storage().Delete(key))
+	}
+	return errors.Join(errs...)
+}
+
+func (w *Widget[S]) storage() SessionStorage {
+	w.storageOnce.Do(func() {
+		if w.Storage == nil {
+			w.Storage = NewSessionStorageMemory()
+		}
+	})
+	return w.Storage
+}
+
+func widgetPayload(payload any) (string, error) {
+	if payload == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(payload)
+	return string(data), err
+}
+
+func widgetMarkupEqual(a, b *InlineKeyboardMarkup) bool {
+	dataA, _ := json.Marshal(a)
+	dataB, _ := json.Marshal(b)
+	return bytes.Equal(dataA, dataB)
+}
+
+func getChatIdOfMessage(message *Message) int64 {
+	if message == nil || message.Chat == nil {
+		return 0
+	}
+	return message.Chat.Id
+}
+
+func getMessageId(message *Message) int64 {
+	if message == nil {
+		return 0
+	}
+	return message.MessageId
+}
+
+// Toggle is a checkbox button of the bool.
+func Toggle(text string, value *bool) *WidgetButton {
+	return &WidgetButton{
+		Text: widgetMark(*value, text),
+		OnPress: func(ctx context.Context, upd *Update) error {
+			*value = !*value
+			return nil
+		},
+	}
+}
+
+// Radio is a row of options, exactly one of them is selected. Label is fmt.Sprint by default.
+func Radio[T comparable](value *T, options []T, label func(option T) string) []*WidgetButton {
+	result := make([]*WidgetButton, len(options))
+	for i, option := range options {
+		text := widgetLabel(option, label)
+		if option == *value {
+			text = "• " + text + " •"
+		}
+		result[i] = &WidgetButton{
+			Text:    text,
+			Payload: option,
+			OnPress: func(ctx context.Context, upd *Update) error {
+				*value = option
+				return nil
+			},
+		}
+	}
+	return result
+}
+
+// MultiSelect is a row of options, any of them might be selected. Label is fmt.Sprint by default.
+func MultiSelect[T comparable](value *[]T, options []T, label func(option T) string) []*WidgetButton {
+	result := make([]*WidgetButton, len(options))
+	for i, option := range options {
+		result[i] = &WidgetButton{
+			Text:    widgetMark(slices.Contains(*value, option), widgetLabel(option, label)),
+			Payload: option,
+			OnPress: func(ctx context.Context, upd *Update) error {
+				if pos := slices.Index(*value, option); pos >= 0 {
+					*value = slices.Delete(*value, pos, pos+1)
+				} else {
+					*value = append(*value, option)
+				}
+				return nil
+			},
+		}
+	}
+	return result
+}
+
+// Counter is a "-", value, "+" row of the int, limited by [low, high].
+func Counter(value *int, low int, high int) []*WidgetButton {
+	step := func(delta int) HandlerFunc {
+		return func(ctx context.Context, upd *Update) error {
+			*value = clamp(*value+delta, low, high)
+			return nil
+		}
+	}
+	return []*WidgetButton{
+		{Text: "−", Payload: -1, OnPress: step(-1)},
+		{Text: strconv.Itoa(*value)},
+		{Text: "+", Payload: 1, OnPress: step(1)},
+	}
+}
+
+func widgetMark(selected bool, text string) string {
+	if selected {
+		return "✅ " + text
+	}
+	return "⬜ " + text
+}
+
+func widgetLabel[T any](option T, label func(option T) string) string {
+	if label == nil {
+		return fmt.Sprint(option)
+	}
+	return label(option)
+}
+
+func clamp(value int, low int, high int) int {
+	return min(max(value, low), high)
+}
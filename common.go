@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"iter"
 	"log/slog"
 	"math/rand/v2"
@@ -196,51 +195,30 @@ func CommonDeleteMessage(ctx context.Context, upd *Update) error {
 
 type CommonArgsHandlerFunc[T any] func(ctx context.Context, upd *Update, args T) error
 
+// CommonArgs parses command arguments into fields of T in order, arguments in "quotes" are single ones.
+// Supported fields: strings, numbers, bool, time.Duration (with days, "1d12h"), time.Time (RFC 3339, "2006-01-02T15:04",
+// "2006-01-02" or "15:04" today), *User (@username, user id or a text mention), encoding.TextUnmarshaler
+// and slices of them, a slice takes all the remaining arguments.
+// Field tags:
+//
+//	type Args struct {
+//		In   time.Duration `arg:"in,required"`
+//		Text string        `arg:"text,rest" default:"ping"` // The rest of the line.
+//		Skip string        `arg:"-"`
+//	}
+//
+// Mistakes in arguments are returned as *ErrorCommandArgs, its Reason is fine to show to the user.
 func CommonArgs[T any](fn CommonArgsHandlerFunc[*T]) HandlerFunc {
 	return func(ctx context.Context, upd *Update) error {
-		fields := slices.Concat(strings.Fields(upd.Message.Text), strings.Fields(upd.Message.Caption))[1:]
 		args := new(T)
-		val := reflect.ValueOf(args).Elem()
-		for i := range min(val.NumField(), len(fields)) {
-			arg := val.Field(i)
-			switch arg.Kind() {
-			case reflect.String:
-				arg.SetString(fields[i])
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				parsed, err := strconv.ParseInt(fields[i], 10, 64)
-				if err != nil {
-					return err
-				}
-				arg.SetInt(parsed)
-			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-				parsed, err := strconv.ParseUint(fields[i], 10, 64)
-				if err != nil {
-					return err
-				}
-				arg.SetUint(parsed)
-			case reflect.Float32, reflect.Float64:
-				parsed, err := strconv.ParseFloat(fields[i], 64)
-				if err != nil {
-					return err
-				}
-				arg.SetFloat(parsed)
-			case reflect.Bool:
-				parsed, err := strconv.ParseBool(fields[i])
-				if err != nil {
-					return err
-				}
-				arg.SetBool(parsed)
-			default:
-				return fmt.Errorf("tg#CommonArgs: unexpected field type %s", arg.Kind().String())
-			}
+		if err := commonArgsParse(upd.Message, reflect.ValueOf(args).Elem()); err != nil {
+			return err
 		}
-
 		return fn(ctx, upd, args)
 	}
 }
 
 func OnCommand(command string) FilterFunc {
-	const botCommandEntity = "bot_command"
 	if !strings.HasPrefix(command, "/") {
 		command = "/" + command
 	}
@@ -251,14 +229,11 @@ func OnCommand(command string) FilterFunc {
 		}
 
 		for _, entity := range upd.Message.Entities {
-			if entity == nil || entity.Type != botCommandEntity {
+			if entity == nil || entity.Type != EntityBotCommand {
 				continue
 			}
 
-			entityText, _, _ := strings.Cut(
-				upd.Message.Text[entity.Offset:entity.Offset+entity.Length],
-				"@",
-			)
+			entityText, _, _ := strings.Cut(upd.Message.EntityText(entity), "@")
 
 			if entityText == command {
 				return true
@@ -446,10 +421,15 @@ func OnCallback(ctx context.Context, upd *Update) bool {
 	return upd != nil && upd.CallbackQuery != nil
 }
 
+func OnInlineQuery(ctx context.Context, upd *Update) bool {
+	return upd != nil && upd.InlineQuery != nil
+}
+
+// OnCallbackWithData filters callbacks with json data of T, only signed data is accepted with Bot.CallbackSigner.
 func OnCallbackWithData[T any](pred ...func(value *T) bool) FilterFunc {
 	predicate := at(pred, 0, func(value *T) bool { return true })
 	return func(ctx context.Context, upd *Update) bool {
-		if upd == nil || upd.CallbackQuery == nil {
+		if upd == nil || upd.CallbackQuery == nil || !callbackDataVerified(ctx) {
 			return false
 		}
 
@@ -529,10 +509,89 @@ func (impl *Message) TextOrCaption() string {
 	}
 }
 
+// MediaFileId returns file_id of the message's photo/video/animation/audio/document/voice/video note/sticker.
+func (impl *Message) MediaFileId() string {
+	switch {
+	case impl == nil:
+		return ""
+	case len(impl.Photo) != 0:
+		return impl.Photo.FileId()
+	case impl.Video != nil:
+		return impl.Video.FileId
+	case impl.Animation != nil: // animation goes before document, both are set for animations.
+		return impl.Animation.FileId
+	case impl.Audio != nil:
+		return impl.Audio.FileId
+	case impl.Document != nil:
+		return impl.Document.FileId
+	case impl.Voice != nil:
+		return impl.Voice.FileId
+	case impl.VideoNote != nil:
+		return impl.VideoNote.FileId
+	case impl.Sticker != nil:
+		return impl.Sticker.FileId
+	default:
+		return ""
+	}
+}
+
 func (impl *Message) TextOrCaptionEntities() iter.Seq[*MessageEntity] {
 	return chainLists(impl.Entities, impl.CaptionEntities)
 }
 
+// EntityText returns the part of text (or caption) covered by the entity, offsets are counted in UTF-16 code units.
+func (impl *Message) EntityText(entity *MessageEntity) string {
+	if impl == nil || entity == nil {
+		return ""
+	}
+	return utf16Substring(impl.TextOrCaption(), entity.Offset, entity.Length)
+}
+
+// EntitiesOfType returns entities of text (or caption) with any of the types, i.e. EntitiesOfType(EntityUrl, EntityTextLink).
+func (impl *Message) EntitiesOfType(types ...string) []*MessageEntity {
+	result := []*MessageEntity{}
+	if impl == nil {
+		return result
+	}
+	for entity := range impl.TextOrCaptionEntities() {
+		if entity != nil && slices.Contains(types, entity.Type) {
+			result = append(result, entity)
+		}
+	}
+	return result
+}
+
+// Urls returns both plain urls and urls of text links.
+func (impl *Message) Urls() []string {
+	result := []string{}
+	for _, entity := range impl.EntitiesOfType(EntityUrl, EntityTextLink) {
+		if entity.Type == EntityTextLink {
+			result = append(result, entity.Url)
+		} else {
+			result = append(result, impl.EntityText(entity))
+		}
+	}
+	return result
+}
+
+// Mentions returns @usernames, text mentions have no username: use EntitiesOfType(EntityTextMention) for them.
+func (impl *Message) Mentions() []string {
+	return impl.entitiesText(EntityMention)
+}
+
+// Hashtags returns #hashtags (with the leading #).
+func (impl *Message) Hashtags() []string {
+	return impl.entitiesText(EntityHashtag)
+}
+
+func (impl *Message) entitiesText(types ...string) []string {
+	result := []string{}
+	for _, entity := range impl.EntitiesOfType(types...) {
+		result = append(result, impl.EntityText(entity))
+	}
+	return result
+}
+
 func isMessagePrivate(msg *Message) bool {
 	return msg.Chat != nil && msg.From != nil && msg.Chat.Id == msg.From.Id
 }
@@ -594,6 +653,44 @@ func getChatId(upd *Update) int64 {
 	return 0
 }
 
+// getSender returns the user behind the update, if any.
+func getSender(upd *Update) *User {
+	switch {
+	case upd == nil:
+		return nil
+	case upd.Message != nil:
+		return upd.Message.From
+	case upd.EditedMessage != nil:
+		return upd.EditedMessage.From
+	case upd.BusinessMessage != nil:
+		return upd.BusinessMessage.From
+	case upd.EditedBusinessMessage != nil:
+		return upd.EditedBusinessMessage.From
+	case upd.InlineQuery != nil:
+		return upd.InlineQuery.From
+	case upd.ChosenInlineResult != nil:
+		return upd.ChosenInlineResult.From
+	case upd.CallbackQuery != nil:
+		return upd.CallbackQuery.From
+	case upd.ShippingQuery != nil:
+		return upd.ShippingQuery.From
+	case upd.PreCheckoutQuery != nil:
+		return upd.PreCheckoutQuery.From
+	case upd.PollAnswer != nil:
+		return upd.PollAnswer.User
+	case upd.ChatJoinRequest != nil:
+		return upd.ChatJoinRequest.From
+	case upd.MyChatMember != nil:
+		return upd.MyChatMember.From
+	case upd.ChatMember != nil:
+		return upd.ChatMember.From
+	case upd.MessageReaction != nil:
+		return upd.MessageReaction.User
+	default:
+		return nil
+	}
+}
+
 func getSenderId(upd *Update) int64 {
 	defer func() { _ = recover() }()
 
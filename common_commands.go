@@ -0,0 +1,549 @@
+package tg
+
+import (
+	"cmp"
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// CommandDefinition declares a command: its menu entry, /help line and arguments,
+// mistakes in arguments are replied to the user together with the usage (the handler is not called).
+//
+// Example:
+//
+//	bot.Commands(&tg.CommandDefinition{
+//		Command:     "/remind",
+//		Aliases:     []string{"/r"},
+//		Description: "Remind me later",
+//		Args: []*tg.CommandArg{
+//			{Name: "in", Type: tg.ArgDuration, Required: true},
+//			{Name: "text", Type: tg.ArgText, Default: "ping"},
+//			{Name: "silent", Type: tg.ArgBool, Flag: true},
+//		},
+//		Handler: func(ctx context.Context, upd *tg.Update, args *tg.CommandArgs) error {
+//			time.AfterFunc(args.Duration("in"), ...)
+//			return nil
+//		},
+//	}).CommandHelp()
+//
+//	// "/remind 1h30m "buy milk" --silent", "/r 10m"
+type CommandDefinition struct {
+	Command string
+	// Aliases are handled as Command, but hidden from menu and /help.
+	Aliases     []string
+	Description string
+	// Descriptions are localized descriptions for the menu and /help, Description is the default one.
+	Descriptions CommandDescriptions
+	// Usage is generated from Args if empty, i.e. "/remind <in> [text...] [--silent]".
+	Usage  string
+	Args   []*CommandArg
+	Scopes []BotCommandScope
+	// Hidden commands are handled, but are not shown in menu and /help.
+	Hidden  bool
+	Handler CommandHandlerFunc
+}
+
+type CommandHandlerFunc func(ctx context.Context, upd *Update, args *CommandArgs) error
+
+type CommandArgType int
+
+const (
+	ArgString CommandArgType = iota
+	ArgInt
+	ArgFloat
+	ArgBool
+	// ArgDuration is time.ParseDuration format with days, i.e. "1d12h" or "90s".
+	ArgDuration
+	// ArgUser is @username, user id or a text mention.
+	ArgUser
+	// ArgText is the rest of the text, only the last positional argument may be ArgText.
+	ArgText
+)
+
+// CommandArg is a positional argument or a --flag (--name=value, --name value or just --name for ArgBool).
+// Quoted values ("buy milk" or 'buy milk') are single arguments.
+type CommandArg struct {
+	Name        string
+	Type        CommandArgType
+	Required    bool
+	Default     string
+	Flag        bool
+	Description string
+}
+
+// CommandArgs are parsed arguments, accessors return zero values for missing optional arguments.
+type CommandArgs struct {
+	values map[string]any
+}
+
+func (args *CommandArgs) Has(name string) bool {
+	_, ok := args.values[name]
+	return ok
+}
+
+func (args *CommandArgs) String(name string) string { return commandArg[string](args, name) }
+func (args *CommandArgs) Int(name string) int64     { return commandArg[int64](args, name) }
+func (args *CommandArgs) Float(name string) float64 { return commandArg[float64](args, name) }
+func (args *CommandArgs) Bool(name string) bool     { return commandArg[bool](args, name) }
+func (args *CommandArgs) User(name string) *User    { return commandArg[*User](args, name) }
+func (args *CommandArgs) Duration(name string) time.Duration {
+	return commandArg[time.Duration](args, name)
+}
+
+func commandArg[T any](args *CommandArgs, name string) T {
+	value, _ := args.values[name].(T)
+	return value
+}
+
+// Commands registers command definitions: handlers (for commands and aliases) and menu entries synced on Start.
+func (bot *Bot) Commands(definitions ...*CommandDefinition) *Bot {
+	for _, definition := range definitions {
+		if !definition.Hidden {
+			descriptions := CommandDescriptions{}
+			if definition.Description != "" {
+				descriptions[""] = definition.Description
+			}
+			for language, description := range definition.Descriptions {
+				descriptions[language] = description
+			}
+			bot.commands.Add(&commandRegistryEntry{
+				Command:      definition.Command,
+				Descriptions: descriptions,
+				Scopes:       definition.Scopes,
+				Definition:   definition,
+			})
+		}
+		bot.Branch(definition.filter(), definition.handle)
+	}
+	return bot
+}
+
+// CommandHelp replies to /help (or the command) with HelpText of registered commands.
+func (bot *Bot) CommandHelp(command ...string) *Bot {
+	return bot.Command(at(command, 0, "/help"), func(ctx context.Context, upd *Update) error {
+		_, err := SendText(ctx, upd.Message.Chat.Id, bot.HelpText(Locale(ctx)))
+		return err
+	})
+}
+
+// HelpText lists registered commands (see Commands and CommandLocalized) with their usage and descriptions in the language.
+func (bot *Bot) HelpText(language string) *Text {
+	bot.commands.mutex.Lock()
+	defer bot.commands.mutex.Unlock()
+
+	text := NewText()
+	for i, entry := range bot.commands.commands {
+		if i != 0 {
+			text.Line()
+		}
+		usage := "/" + entry.Command
+		if entry.Definition != nil {
+			usage = entry.Definition.usage()
+		}
+		text.Bold(usage)
+
+		description, ok := entry.Descriptions[language]
+		if base, _, _ := strings.Cut(language, "-"); !ok {
+			description, ok = entry.Descriptions[base]
+		}
+		if !ok {
+			description = entry.Descriptions[""]
+		}
+		if description != "" {
+			text.Plain(" — " + description)
+		}
+	}
+	return text
+}
+
+func (definition *CommandDefinition) filter() FilterFunc {
+	filters := []FilterFunc{OnCommand(definition.Command)}
+	for _, alias := range definition.Aliases {
+		filters = append(filters, OnCommand(alias))
+	}
+	return func(ctx context.Context, upd *Update) bool {
+		return slices.ContainsFunc(filters, func(filter FilterFunc) bool { return filter(ctx, upd) })
+	}
+}
+
+func (definition *CommandDefinition) handle(ctx context.Context, upd *Update) error {
+	args, err := definition.parse(upd.Message)
+	var errArgs *ErrorCommandArgs
+	if errors.As(err, &errArgs) {
+		_, err = SendMessage(ctx, upd.Message.Chat.Id, errArgs.Reason+"\nUsage: "+definition.usage(), &OptSendMessage{
+			ReplyParameters: &ReplyParameters{MessageId: upd.Message.MessageId, AllowSendingWithoutReply: true},
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if definition.Handler == nil {
+		return nil
+	}
+	return definition.Handler(ctx, upd, args)
+}
+
+func (definition *CommandDefinition) usage() string {
+	if definition.Usage != "" {
+		return definition.Usage
+	}
+	usage := []string{"/" + strings.TrimPrefix(definition.Command, "/")}
+	for _, arg := range definition.Args {
+		name := arg.Name
+		switch {
+		case arg.Flag && arg.Type == ArgBool:
+			name = "--" + name
+		case arg.Flag:
+			name = "--" + name + " <" + arg.Name + ">"
+		case arg.Type == ArgText:
+			name += "..."
+		}
+		if arg.Required && !arg.Flag {
+			usage = append(usage, "<"+name+">")
+		} else {
+			usage = append(usage, "["+name+"]")
+		}
+	}
+	return strings.Join(usage, " ")
+}
+
+// parse parses arguments of the message, ErrorCommandArgs is returned for user's mistakes.
+func (definition *CommandDefinition) parse(msg *Message) (*CommandArgs, error) {
+	tokens, err := commandTokenize(commandArgsText(msg))
+	if err != nil {
+		return nil, &ErrorCommandArgs{Command: definition.Command, Reason: err.Error()}
+	}
+
+	args := &CommandArgs{values: map[string]any{}}
+	positional := []*CommandArg{}
+	flags := map[string]*CommandArg{}
+	for _, arg := range definition.Args {
+		if arg.Flag {
+			flags[arg.Name] = arg
+		} else {
+			positional = append(positional, arg)
+		}
+	}
+
+	values := []string{}
+	for i := 0; i < len(tokens); i++ {
+		name, ok := strings.CutPrefix(tokens[i].Value, "--")
+		if !ok || tokens[i].Quoted {
+			values = append(values, tokens[i].Value)
+			continue
+		}
+		name, value, hasValue := strings.Cut(name, "=")
+		arg, ok := flags[name]
+		if !ok {
+			return nil, &ErrorCommandArgs{Command: definition.Command, Arg: name, Reason: fmt.Sprintf("unknown flag --%s", name)}
+		}
+		if !hasValue && arg.Type == ArgBool {
+			value = "true"
+		} else if !hasValue {
+			if i+1 >= len(tokens) {
+				return nil, &ErrorCommandArgs{Command: definition.Command, Arg: name, Reason: fmt.Sprintf("flag --%s requires a value", name)}
+			}
+			i++
+			value = tokens[i].Value
+		}
+		if err = args.set(msg, arg, value); err != nil {
+			return nil, &ErrorCommandArgs{Command: definition.Command, Arg: name, Reason: err.Error()}
+		}
+	}
+
+	for i, arg := range positional {
+		switch {
+		case arg.Type == ArgText && i < len(values):
+			values = append(values[:i], strings.Join(values[i:], " "))
+		case i >= len(values) && arg.Required:
+			return nil, &ErrorCommandArgs{Command: definition.Command, Arg: arg.Name, Reason: fmt.Sprintf("missing argument <%s>", arg.Name)}
+		case i >= len(values):
+			continue
+		}
+		if err = args.set(msg, arg, values[i]); err != nil {
+			return nil, &ErrorCommandArgs{Command: definition.Command, Arg: arg.Name, Reason: err.Error()}
+		}
+	}
+	if len(values) > len(positional) {
+		return nil, &ErrorCommandArgs{Command: definition.Command, Reason: fmt.Sprintf("unexpected argument %q", values[len(positional)])}
+	}
+
+	for _, arg := range definition.Args {
+		if args.Has(arg.Name) {
+			continue
+		}
+		if arg.Flag && arg.Required {
+			return nil, &ErrorCommandArgs{Command: definition.Command, Arg: arg.Name, Reason: fmt.Sprintf("missing flag --%s", arg.Name)}
+		}
+		if arg.Default != "" {
+			if err = args.set(msg, arg, arg.Default); err != nil {
+				return nil, fmt.Errorf("tg#CommandDefinition: bad default of %s: %w", arg.Name, err)
+			}
+		}
+	}
+	return args, nil
+}
+
+func (args *CommandArgs) set(msg *Message, arg *CommandArg, value string) error {
+	var parsed any
+	var err error
+	switch arg.Type {
+	case ArgString, ArgText:
+		parsed = value
+	case ArgInt:
+		parsed, err = strconv.ParseInt(value, 10, 64)
+	case ArgFloat:
+		parsed, err = strconv.ParseFloat(value, 64)
+	case ArgBool:
+		parsed, err = strconv.ParseBool(value)
+	case ArgDuration:
+		parsed, err = parseDuration(value)
+	case ArgUser:
+		parsed, err = parseUser(msg, value)
+	default:
+		err = fmt.Errorf("unknown argument type %d", arg.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("bad value %q of <%s>", value, arg.Name)
+	}
+	args.values[arg.Name] = parsed
+	return nil
+}
+
+// parseDuration is time.ParseDuration with days: "1d12h".
+func parseDuration(value string) (time.Duration, error) {
+	days, rest, ok := strings.Cut(value, "d")
+	if !ok {
+		return time.ParseDuration(value)
+	}
+	count, err := strconv.ParseInt(days, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	duration := time.Duration(0)
+	if rest != "" {
+		if duration, err = time.ParseDuration(rest); err != nil {
+			return 0, err
+		}
+	}
+	return time.Duration(count)*24*time.Hour + duration, nil
+}
+
+// parseUser parses @username, user id or finds the text mention covering the value.
+func parseUser(msg *Message, value string) (*User, error) {
+	for _, entity := range msg.EntitiesOfType(EntityTextMention) {
+		if entity.User != nil && msg.EntityText(entity) == value {
+			return entity.User, nil
+		}
+	}
+	if username, ok := strings.CutPrefix(value, "@"); ok && username != "" {
+		return &User{Username: username}, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &User{Id: id}, nil
+}
+
+type commandToken struct {
+	Value  string
+	Quoted bool
+}
+
+// commandArgsText is the text after the command.
+func commandArgsText(msg *Message) string {
+	text := strings.TrimSpace(msg.TextOrCaption())
+	if space := strings.IndexFunc(text, unicode.IsSpace); space >= 0 {
+		return text[space:]
+	}
+	return ""
+}
+
+func commonArgsParse(msg *Message, val reflect.Value) error {
+	command := ""
+	if msg == nil {
+		msg = &Message{}
+	} else if fields := strings.Fields(msg.TextOrCaption()); len(fields) > 0 {
+		command = fields[0]
+	}
+	tokens, err := commandTokenize(commandArgsText(msg))
+	if err != nil {
+		return &ErrorCommandArgs{Command: command, Reason: err.Error()}
+	}
+
+	position := 0
+	for i := range val.NumField() {
+		field, fieldType := val.Field(i), val.Type().Field(i)
+		tag := fieldType.Tag.Get("arg")
+		if !fieldType.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		name = cmp.Or(name, fieldType.Name)
+		required := slices.Contains(strings.Split(options, ","), "required")
+		rest := slices.Contains(strings.Split(options, ","), "rest")
+
+		// []byte and TextUnmarshaler slices (e.g. net.IP) are single values, other slices take the remaining tokens.
+		list := field.Kind() == reflect.Slice && !commonArgsScalar(field)
+		values := []string{}
+		switch {
+		case position < len(tokens) && rest && !list:
+			for _, token := range tokens[position:] {
+				values = append(values, token.Value)
+			}
+			values, position = []string{strings.Join(values, " ")}, len(tokens)
+		case position < len(tokens) && list:
+			for _, token := range tokens[position:] {
+				values = append(values, token.Value)
+			}
+			position = len(tokens)
+		case position < len(tokens):
+			values, position = []string{tokens[position].Value}, position+1
+		case fieldType.Tag.Get("default") != "":
+			values = []string{fieldType.Tag.Get("default")}
+		case required:
+			return &ErrorCommandArgs{Command: command, Arg: name, Reason: fmt.Sprintf("missing argument <%s>", name)}
+		default:
+			continue
+		}
+
+		if list {
+			field.Set(reflect.MakeSlice(field.Type(), len(values), len(values)))
+			for j, value := range values {
+				if err := commonArgsSet(msg, field.Index(j), value); err != nil {
+					return &ErrorCommandArgs{Command: command, Arg: name, Reason: fmt.Sprintf("bad value %q of <%s>", value, name)}
+				}
+			}
+			continue
+		}
+		if err := commonArgsSet(msg, field, values[0]); err != nil {
+			return &ErrorCommandArgs{Command: command, Arg: name, Reason: fmt.Sprintf("bad value %q of <%s>", values[0], name)}
+		}
+	}
+	return nil
+}
+
+// commonArgsScalar reports whether the slice is parsed from a single value, i.e. []byte or a TextUnmarshaler slice type.
+func commonArgsScalar(field reflect.Value) bool {
+	_, unmarshaler := field.Addr().Interface().(encoding.TextUnmarshaler)
+	return unmarshaler || field.Type().Elem().Kind() == reflect.Uint8
+}
+
+func commonArgsSet(msg *Message, field reflect.Value, value string) error {
+	switch field.Interface().(type) {
+	case time.Duration:
+		parsed, err := parseDuration(value)
+		field.SetInt(int64(parsed))
+		return err
+	case time.Time:
+		parsed, err := parseTime(value)
+		field.Set(reflect.ValueOf(parsed))
+		return err
+	case *User:
+		parsed, err := parseUser(msg, value)
+		field.Set(reflect.ValueOf(parsed))
+		return err
+	}
+
+	if field.CanAddr() {
+		if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return unmarshaler.UnmarshalText([]byte(value))
+		}
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Slice:
+		field.SetBytes([]byte(value))
+	default:
+		return fmt.Errorf("tg#CommonArgs: unexpected field type %s", field.Type().String())
+	}
+	return nil
+}
+
+// parseTime parses RFC 3339, "2006-01-02T15:04", "2006-01-02" or "15:04" (today) in the local time zone.
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", time.DateOnly} {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return parsed, nil
+		}
+	}
+	clock, err := time.ParseInLocation("15:04", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, time.Local), nil
+}
+
+// commandTokenize splits by spaces, "double" or 'single' quoted parts are single tokens, \ escapes quotes inside.
+func commandTokenize(text string) ([]*commandToken, error) {
+	tokens := []*commandToken{}
+	current, quote, started, quoted := strings.Builder{}, rune(0), false, false
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		char := runes[i]
+		switch {
+		case quote != 0 && char == '\\' && i+1 < len(runes) && (runes[i+1] == quote || runes[i+1] == '\\'):
+			current.WriteRune(runes[i+1])
+			i++
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(char)
+		case (char == '"' || char == '\'') && !started: // "it's" is a single token.
+			quote, started, quoted = char, true, true
+		case unicode.IsSpace(char):
+			if started {
+				tokens = append(tokens, &commandToken{Value: current.String(), Quoted: quoted})
+			}
+			current.Reset()
+			started, quoted = false, false
+		default:
+			current.WriteRune(char)
+			started = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unclosed quote %c", quote)
+	}
+	if started {
+		tokens = append(tokens, &commandToken{Value: current.String(), Quoted: quoted})
+	}
+	return tokens, nil
+}
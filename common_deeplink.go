@@ -0,0 +1,115 @@
+package tg
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type DeepLinkType string
+
+// Deep link types, check https://core.telegram.org/bots/features#deep-linking.
+const (
+	// DeepLinkStart opens a private chat with the bot, the payload comes as "/start <payload>".
+	DeepLinkStart DeepLinkType = "start"
+	// DeepLinkStartGroup adds the bot to a group, the payload comes as "/start@bot <payload>".
+	DeepLinkStartGroup DeepLinkType = "startgroup"
+	// DeepLinkStartApp opens the main Mini App, the payload is passed as start_param.
+	DeepLinkStartApp DeepLinkType = "startapp"
+)
+
+var deepLinkPayloadRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]*$`)
+
+// DeepLink generates t.me link with the payload ([A-Za-z0-9_-], up to 64 characters, 512 for startapp),
+// the type is DeepLinkStart by default. Use EncodePayload for structured payloads.
+//
+// Example:
+//
+//	link, err := tg.DeepLink("kittenbark_bot", "ref_42")                       // https://t.me/kittenbark_bot?start=ref_42
+//	link, err := tg.DeepLink("kittenbark_bot", "ref_42", tg.DeepLinkStartGroup) // https://t.me/kittenbark_bot?startgroup=ref_42
+func DeepLink(botUsername string, payload string, linkType ...DeepLinkType) (string, error) {
+	kind := at(linkType, 0, DeepLinkStart)
+	limit := 64
+	if kind == DeepLinkStartApp {
+		limit = 512
+	}
+	if len(payload) > limit || !deepLinkPayloadRegexp.MatchString(payload) {
+		return "", fmt.Errorf("tg.DeepLink: bad payload %q, expected up to %d characters of [A-Za-z0-9_-]", payload, limit)
+	}
+
+	link := "https://t.me/" + url.PathEscape(strings.TrimPrefix(botUsername, "@"))
+	if payload == "" && kind != DeepLinkStartApp {
+		return link + "?" + string(kind), nil
+	}
+	return link + "?" + string(kind) + "=" + payload, nil
+}
+
+// EncodePayload encodes the value as base64url JSON after the prefix, DecodePayload and CommonStartPayload reverse it.
+// Keep in mind the 64 characters limit: short json field names help a lot.
+func EncodePayload(prefix string, value any) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodePayload decodes EncodePayload result, the prefix must match.
+func DecodePayload[T any](payload string, prefix string) (*T, error) {
+	encoded, ok := strings.CutPrefix(payload, prefix)
+	if !ok {
+		return nil, fmt.Errorf("tg.DecodePayload: expected prefix %q in %q", prefix, payload)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return nil, err
+	}
+	result := new(T)
+	if err = json.Unmarshal(data, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// StartPayload returns the payload of "/start <payload>" message, empty if there is none.
+func StartPayload(upd *Update) string {
+	if upd == nil || upd.Message == nil || !OnCommand("/start")(context.Background(), upd) {
+		return ""
+	}
+	fields := strings.Fields(upd.Message.Text)
+	return at(fields, 1, "")
+}
+
+// OnStartPayload filters /start with a payload starting with the prefix (any non-empty payload for "").
+func OnStartPayload(prefix string) FilterFunc {
+	return func(ctx context.Context, upd *Update) bool {
+		payload := StartPayload(upd)
+		return payload != "" && strings.HasPrefix(payload, prefix)
+	}
+}
+
+// CommonStartPayload decodes EncodePayload-ed payload of /start into T.
+//
+// Example:
+//
+//	type Referral struct {
+//		From int64 `json:"f"`
+//	}
+//	link, _ := tg.EncodePayload("ref_", &Referral{From: upd.Message.From.Id}) // -> tg.DeepLink(botUsername, link)
+//	...
+//	bot.Branch(tg.OnStartPayload("ref_"), tg.CommonStartPayload("ref_", func(ctx context.Context, upd *tg.Update, ref *Referral) error {
+//		...
+//	}))
+func CommonStartPayload[T any](prefix string, fn CommonArgsHandlerFunc[*T]) HandlerFunc {
+	return func(ctx context.Context, upd *Update) error {
+		payload, err := DecodePayload[T](StartPayload(upd), prefix)
+		if err != nil {
+			return err
+		}
+		return fn(ctx, upd, payload)
+	}
+}
@@ -0,0 +1,286 @@
+package tg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FSM routes updates of a chat+user pair by their conversation state, handlers move between states with SetState.
+//
+// Example:
+//
+//	fsm := tg.NewFSM().
+//		Timeout(time.Minute*10).
+//		CancelCommands("/cancel").
+//		OnCancel(tg.CommonTextReply("ok, forget it")).
+//		State("name", func(ctx context.Context, upd *tg.Update) error {
+//			if err := tg.SetState(ctx, upd, "age", &Form{Name: upd.Message.Text}); err != nil {
+//				return err
+//			}
+//			return tg.CommonTextReply("how old are you?")(ctx, upd)
+//		}).
+//		State("age", func(ctx context.Context, upd *tg.Update) error {
+//			form, err := tg.StateData[Form](ctx, upd)
+//			...
+//			return tg.ClearState(ctx, upd)
+//		})
+//
+//	tg.NewFromEnv().
+//		FSM(fsm). // States are handled here, the rest goes further.
+//		Command("/form", func(ctx context.Context, upd *tg.Update) error {
+//			if err := tg.SetState(ctx, upd, "name"); err != nil {
+//				return err
+//			}
+//			return tg.CommonTextReply("what is your name?")(ctx, upd)
+//		}).
+//		Start()
+type FSM struct {
+	storage   StateStorage
+	timeout   time.Duration
+	cancel    []string
+	onCancel  HandlerFunc
+	onTimeout HandlerFunc
+	states    map[string]HandlerFunc
+}
+
+// State is the conversation state of a chat+user pair, Data is set by SetState.
+type State struct {
+	Name      string          `json:"name"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// NewFSM creates FSM, states are kept in memory by default.
+func NewFSM(storage ...StateStorage) *FSM {
+	return &FSM{
+		storage: at(storage, 0, NewStateStorageMemory()),
+		states:  map[string]HandlerFunc{},
+	}
+}
+
+// State registers the handler of the state.
+func (fsm *FSM) State(name string, handler HandlerFunc) *FSM {
+	fsm.states[name] = handler
+	return fsm
+}
+
+// Timeout makes states expire after the duration since the last SetState, the expired state is cleared
+// on the next update of the chat+user (see OnTimeout).
+func (fsm *FSM) Timeout(timeout time.Duration) *FSM {
+	fsm.timeout = timeout
+	return fsm
+}
+
+// CancelCommands clear the state in any state, i.e. "/cancel" (see OnCancel).
+func (fsm *FSM) CancelCommands(commands ...string) *FSM {
+	fsm.cancel = append(fsm.cancel, commands...)
+	return fsm
+}
+
+// OnCancel handles cancel commands after the state is cleared.
+func (fsm *FSM) OnCancel(handler HandlerFunc) *FSM {
+	fsm.onCancel = handler
+	return fsm
+}
+
+// OnTimeout handles the first update after the state expired, the update is not handled further.
+// Without OnTimeout the update goes through the pipeline as if there were no state.
+func (fsm *FSM) OnTimeout(handler HandlerFunc) *FSM {
+	fsm.onTimeout = handler
+	return fsm
+}
+
+// FSM handles updates of chats+users with a state (and cancel commands) at this point of the pipeline,
+// SetState, ClearState and StateData work in every handler of the bot.
+func (bot *Bot) FSM(fsm *FSM) *Bot {
+	bot.context = context.WithValue(bot.context, ContextFSM, fsm)
+	return bot.complexBranch(Branch().Filter(fsm.filter).Handle(fsm.handle))
+}
+
+// SetState moves the chat+user of the update into the state, optional data is kept as JSON (see StateData).
+func SetState(ctx context.Context, upd *Update, name string, data ...any) error {
+	fsm, err := contextFSM(ctx)
+	if err != nil {
+		return err
+	}
+	state := &State{Name: name}
+	if len(data) > 0 {
+		if state.Data, err = json.Marshal(data[0]); err != nil {
+			return err
+		}
+	}
+	if fsm.timeout > 0 {
+		state.ExpiresAt = time.Now().Add(fsm.timeout)
+	}
+	return fsm.storage.Store(fsm.key(upd), state)
+}
+
+// GetState returns the current state of the chat+user of the update, nil if there is none (or it expired).
+func GetState(ctx context.Context, upd *Update) *State {
+	fsm, err := contextFSM(ctx)
+	if err != nil {
+		return nil
+	}
+	state, _ := fsm.load(upd)
+	return state
+}
+
+// ClearState finishes the conversation of the chat+user of the update.
+func ClearState(ctx context.Context, upd *Update) error {
+	fsm, err := contextFSM(ctx)
+	if err != nil {
+		return err
+	}
+	return fsm.storage.Delete(fsm.key(upd))
+}
+
+// StateData decodes data of the current state, set with SetState.
+func StateData[T any](ctx context.Context, upd *Update) (*T, error) {
+	state := GetState(ctx, upd)
+	if state == nil {
+		return nil, &Error{Description: "tg.StateData: no state"}
+	}
+	result := new(T)
+	if len(state.Data) == 0 {
+		return result, nil
+	}
+	if err := json.Unmarshal(state.Data, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func contextFSM(ctx context.Context) (*FSM, error) {
+	fsm, ok := ctx.Value(ContextFSM).(*FSM)
+	if !ok {
+		return nil, &Error{Description: "fsm not found in context, use Bot.FSM"}
+	}
+	return fsm, nil
+}
+
+func (fsm *FSM) key(upd *Update) string {
+	return fmt.Sprintf("%d:%d", getChatId(upd), getSenderId(upd))
+}
+
+// load returns the current state, expired is true if the state has just expired (and was cleared).
+func (fsm *FSM) load(upd *Update) (state *State, expired bool) {
+	key := fsm.key(upd)
+	state, ok := fsm.storage.Load(key)
+	if !ok {
+		return nil, false
+	}
+	if !state.ExpiresAt.IsZero() && time.Now().After(state.ExpiresAt) {
+		if err := fsm.storage.Delete(key); err != nil {
+			return nil, false
+		}
+		return nil, true
+	}
+	return state, false
+}
+
+func (fsm *FSM) filter(ctx context.Context, upd *Update) bool {
+	state, expired := fsm.load(upd)
+	switch {
+	case expired:
+		return fsm.onTimeout != nil
+	case state == nil:
+		return false
+	case fsm.isCancel(ctx, upd):
+		return true
+	default:
+		_, ok := fsm.states[state.Name]
+		return ok
+	}
+}
+
+func (fsm *FSM) handle(ctx context.Context, upd *Update) error {
+	state, _ := fsm.load(upd)
+	switch {
+	case state == nil && fsm.onTimeout != nil:
+		return fsm.onTimeout(ctx, upd)
+	case state == nil:
+		return nil
+	case fsm.isCancel(ctx, upd):
+		if err := fsm.storage.Delete(fsm.key(upd)); err != nil || fsm.onCancel == nil {
+			return err
+		}
+		return fsm.onCancel(ctx, upd)
+	default:
+		return fsm.states[state.Name](ctx, upd)
+	}
+}
+
+func (fsm *FSM) isCancel(ctx context.Context, upd *Update) bool {
+	for _, command := range fsm.cancel {
+		if OnCommand(command)(ctx, upd) {
+			return true
+		}
+	}
+	return false
+}
+
+// StateStorage persists states of FSM by chat+user keys.
+type StateStorage interface {
+	Load(key string) (state *State, ok bool)
+	Store(key string, state *State) error
+	Delete(key string) error
+}
+
+var (
+	_ StateStorage = (*stateStorageMemory)(nil)
+	_ StateStorage = (*stateStorageJSON)(nil)
+)
+
+func NewStateStorageMemory() StateStorage {
+	return &stateStorageMemory{}
+}
+
+type stateStorageMemory struct {
+	states sync.Map
+}
+
+func (storage *stateStorageMemory) Load(key string) (*State, bool) {
+	state, ok := storage.states.Load(key)
+	if !ok {
+		return nil, false
+	}
+	return state.(*State), true
+}
+
+func (storage *stateStorageMemory) Store(key string, state *State) error {
+	storage.states.Store(key, state)
+	return nil
+}
+
+func (storage *stateStorageMemory) Delete(key string) error {
+	storage.states.Delete(key)
+	return nil
+}
+
+// NewStateStorageJSON persists states in the JSON file, so conversations survive restarts.
+func NewStateStorageJSON(path string) (StateStorage, error) {
+	store, err := newJSONFileStore[*State]("state storage", path)
+	if err != nil {
+		return nil, err
+	}
+	return &stateStorageJSON{store: store}, nil
+}
+
+type stateStorageJSON struct {
+	store *jsonFileStore[*State]
+}
+
+func (storage *stateStorageJSON) Load(key string) (*State, bool) {
+	return storage.store.Load(key)
+}
+
+func (storage *stateStorageJSON) Store(key string, state *State) error {
+	return storage.store.Store(key, state)
+}
+
+func (storage *stateStorageJSON) Delete(key string) error {
+	return storage.store.Delete(key)
+}
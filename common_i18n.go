@@ -0,0 +1,437 @@
+package tg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// I18n keeps message catalogs, one per locale. Catalogs are JSON files named by locale (en.json, pt-br.json, ...),
+// values are text/template strings, objects with plural forms (zero, one, two, few, many, other) or nested groups
+// of messages addressed with dots:
+//
+//	{
+//		"hello": "Hi, {{.Message.From.FirstName}}!",
+//		"cats": {"one": "{{.Count}} cat", "other": "{{.Count}} cats"},
+//		"errors": {"not_found": "Nothing found"}
+//	}
+//
+// Usage:
+//
+//	i18n := tg.NewI18n("en")
+//	if err := i18n.LoadDir("locales"); err != nil {
+//		panic(err)
+//	}
+//	tg.NewFromEnv().
+//		I18n(i18n).
+//		Command("/start", tg.CommonTextReplyT("hello")).
+//		Branch(tg.OnText, func(ctx context.Context, upd *tg.Update) error {
+//			_, err := tg.SendMessage(ctx, upd.Message.Chat.Id, tg.TN(ctx, "cats", 3))
+//			return err
+//		}).
+//		Start()
+type I18n struct {
+	fallback string
+	locale   func(ctx context.Context, upd *Update) string
+	mutex    sync.RWMutex
+	catalogs map[string]map[string]*i18nMessage
+	plurals  map[string]PluralRule
+}
+
+// PluralRule returns CLDR plural category for the count: zero, one, two, few, many or other.
+type PluralRule func(count int64) string
+
+type i18nMessage struct {
+	Text    *template.Template
+	Plurals map[string]*template.Template
+}
+
+var i18nPluralCategories = []string{"zero", "one", "two", "few", "many", "other"}
+
+// NewI18n creates I18n, fallback locale is used when neither the user's locale nor its base language has a translation.
+func NewI18n(fallback string) *I18n {
+	return &I18n{
+		fallback: i18nNormalize(fallback),
+		locale:   i18nSenderLocale,
+		catalogs: map[string]map[string]*i18nMessage{},
+		plurals:  map[string]PluralRule{},
+	}
+}
+
+// LocaleFunc replaces the default locale resolution (sender's User.LanguageCode), i.e. with a language chosen in settings.
+func (i18n *I18n) LocaleFunc(fn func(ctx context.Context, upd *Update) string) *I18n {
+	i18n.locale = fn
+	return i18n
+}
+
+// PluralRule overrides the plural rule of the locale (or of the whole language, i.e. "pt").
+func (i18n *I18n) PluralRule(locale string, rule PluralRule) *I18n {
+	i18n.mutex.Lock()
+	defer i18n.mutex.Unlock()
+	i18n.plurals[i18nNormalize(locale)] = rule
+	return i18n
+}
+
+// LoadDir loads every *.json catalog from the directory.
+func (i18n *I18n) LoadDir(dir string) error {
+	return i18n.LoadFS(os.DirFS(dir))
+}
+
+// LoadFS loads every *.json catalog from the root of fsys, handy with embed.FS.
+func (i18n *I18n) LoadFS(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		if err = i18n.LoadJSON(strings.TrimSuffix(path.Base(file), ".json"), data); err != nil {
+			return fmt.Errorf("tg.I18n: %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// LoadJSON adds messages of the locale, existing keys are replaced.
+func (i18n *I18n) LoadJSON(locale string, data []byte) error {
+	messages := map[string]any{}
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return err
+	}
+	return i18n.Add(locale, messages)
+}
+
+// Add adds messages of the locale: values are strings, maps of plural forms or nested maps.
+func (i18n *I18n) Add(locale string, messages map[string]any) error {
+	parsed := map[string]*i18nMessage{}
+	if err := i18nParse(parsed, "", messages); err != nil {
+		return err
+	}
+
+	i18n.mutex.Lock()
+	defer i18n.mutex.Unlock()
+	locale = i18nNormalize(locale)
+	if i18n.catalogs[locale] == nil {
+		i18n.catalogs[locale] = map[string]*i18nMessage{}
+	}
+	for key, message := range parsed {
+		i18n.catalogs[locale][key] = message
+	}
+	return nil
+}
+
+// Locales lists loaded locales.
+func (i18n *I18n) Locales() []string {
+	i18n.mutex.RLock()
+	defer i18n.mutex.RUnlock()
+	locales := make([]string, 0, len(i18n.catalogs))
+	for locale := range i18n.catalogs {
+		locales = append(locales, locale)
+	}
+	return locales
+}
+
+// T translates the key, data is passed to the template. Missing keys are returned as is.
+func (i18n *I18n) T(locale string, key string, data ...any) string {
+	message := i18n.lookup(locale, key)
+	if message == nil {
+		return key
+	}
+	if message.Text == nil {
+		return i18n.plural(locale, message, 1, at(data, 0, any(nil)))
+	}
+	return i18nExecute(message.Text, at(data, 0, any(nil)))
+}
+
+// TN translates the key choosing the plural form for count,
+// data is passed to the template (map[string]any{"Count": count} if data is not set).
+func (i18n *I18n) TN(locale string, key string, count int64, data ...any) string {
+	message := i18n.lookup(locale, key)
+	switch {
+	case message == nil:
+		return key
+	case message.Text != nil:
+		return i18nExecute(message.Text, at(data, 0, any(map[string]any{"Count": count})))
+	default:
+		return i18n.plural(locale, message, count, at(data, 0, any(map[string]any{"Count": count})))
+	}
+}
+
+// Descriptions translates the key into every loaded locale for Bot.CommandLocalized, the fallback one is the default.
+func (i18n *I18n) Descriptions(key string) CommandDescriptions {
+	descriptions := CommandDescriptions{}
+	for _, locale := range i18n.Locales() {
+		language := locale
+		if locale == i18n.fallback {
+			language = ""
+		}
+		descriptions[language] = i18n.T(locale, key)
+	}
+	return descriptions
+}
+
+// Middleware resolves the locale of the update and puts it into the context (ContextLocale), Bot.I18n does it for you.
+func (i18n *I18n) Middleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, upd *Update) error {
+		ctx = context.WithValue(ctx, ContextI18n, i18n)
+		ctx = context.WithValue(ctx, ContextLocale, i18n.resolve(i18n.locale(ctx, upd)))
+		return next(ctx, upd)
+	}
+}
+
+// resolve finds the best loaded locale: exact match, base language (pt-br -> pt) or the fallback.
+func (i18n *I18n) resolve(locale string) string {
+	i18n.mutex.RLock()
+	defer i18n.mutex.RUnlock()
+	locale = i18nNormalize(locale)
+	if _, ok := i18n.catalogs[locale]; ok {
+		return locale
+	}
+	language, _, _ := strings.Cut(locale, "-")
+	if _, ok := i18n.catalogs[language]; ok {
+		return language
+	}
+	return i18n.fallback
+}
+
+func (i18n *I18n) lookup(locale string, key string) *i18nMessage {
+	locale = i18n.resolve(locale)
+	i18n.mutex.RLock()
+	defer i18n.mutex.RUnlock()
+	if message, ok := i18n.catalogs[locale][key]; ok {
+		return message
+	}
+	if message, ok := i18n.catalogs[i18n.fallback][key]; ok {
+		return message
+	}
+	slog.Warn("tg.I18n#missing_key", "locale", locale, "key", key)
+	return nil
+}
+
+func (i18n *I18n) plural(locale string, message *i18nMessage, count int64, data any) string {
+	category := i18n.pluralRule(locale)(count)
+	form, ok := message.Plurals[category]
+	if !ok {
+		form = message.Plurals["other"]
+	}
+	return i18nExecute(form, data)
+}
+
+func (i18n *I18n) pluralRule(locale string) PluralRule {
+	locale = i18nNormalize(locale)
+	language, _, _ := strings.Cut(locale, "-")
+	i18n.mutex.RLock()
+	defer i18n.mutex.RUnlock()
+	for _, key := range []string{locale, language} {
+		if rule, ok := i18n.plurals[key]; ok {
+			return rule
+		}
+		if rule, ok := i18nPluralRules[key]; ok {
+			return rule
+		}
+	}
+	return pluralRuleOneOther
+}
+
+// Locale returns the locale resolved by I18n middleware.
+func Locale(ctx context.Context) string {
+	return getOrDefault(ctx, ContextLocale, "")
+}
+
+// T translates the key into the locale of the update, see I18n.T.
+func T(ctx context.Context, key string, data ...any) string {
+	i18n, ok := ctx.Value(ContextI18n).(*I18n)
+	if !ok {
+		return key
+	}
+	return i18n.T(Locale(ctx), key, data...)
+}
+
+// TN translates the key into the locale of the update choosing the plural form for count, see I18n.TN.
+func TN(ctx context.Context, key string, count int64, data ...any) string {
+	i18n, ok := ctx.Value(ContextI18n).(*I18n)
+	if !ok {
+		return key
+	}
+	return i18n.TN(Locale(ctx), key, count, data...)
+}
+
+// CommonTextReplyT is CommonTextReply with the translated key, the update is passed to the template.
+func CommonTextReplyT(key string, asReply ...bool) HandlerFunc {
+	isReply := at(asReply, 0, false)
+	return func(ctx context.Context, upd *Update) error {
+		opts := []*OptSendMessage{}
+		if isReply {
+			opts = append(opts, &OptSendMessage{ReplyParameters: &ReplyParameters{
+				MessageId:                upd.Message.MessageId,
+				AllowSendingWithoutReply: true,
+			}})
+		}
+		_, err := SendMessage(ctx, upd.Message.Chat.Id, T(ctx, key, upd), opts...)
+		return err
+	}
+}
+
+func i18nSenderLocale(ctx context.Context, upd *Update) string {
+	return deref(getSender(upd)).LanguageCode
+}
+
+func i18nNormalize(locale string) string {
+	return strings.ReplaceAll(strings.ToLower(locale), "_", "-")
+}
+
+func i18nParse(result map[string]*i18nMessage, prefix string, messages map[string]any) error {
+	for key, value := range messages {
+		key = prefix + key
+		switch value := value.(type) {
+		case string:
+			text, err := template.New(key).Parse(value)
+			if err != nil {
+				return err
+			}
+			result[key] = &i18nMessage{Text: text}
+		case map[string]any:
+			if !i18nIsPlural(value) {
+				if err := i18nParse(result, key+".", value); err != nil {
+					return err
+				}
+				continue
+			}
+			message := &i18nMessage{Plurals: map[string]*template.Template{}}
+			for category, form := range value {
+				text, err := template.New(key + "#" + category).Parse(form.(string))
+				if err != nil {
+					return err
+				}
+				message.Plurals[category] = text
+			}
+			result[key] = message
+		default:
+			return fmt.Errorf("unexpected value of %q: %v", key, value)
+		}
+	}
+	return nil
+}
+
+// i18nIsPlural detects plural forms: an object with "other" and only plural categories as string keys.
+func i18nIsPlural(value map[string]any) bool {
+	if _, ok := value["other"]; !ok {
+		return false
+	}
+	for category, form := range value {
+		if _, ok := form.(string); !ok || !containsAny(i18nPluralCategories, []string{category}) {
+			return false
+		}
+	}
+	return true
+}
+
+func i18nExecute(text *template.Template, data any) string {
+	result := &strings.Builder{}
+	if err := text.Execute(result, data); err != nil {
+		slog.Warn("tg.I18n#template", "name", text.Name(), "err", err)
+		return text.Root.String()
+	}
+	return result.String()
+}
+
+// i18nPluralRules are simplified CLDR rules (https://cldr.unicode.org/index/cldr-spec/plural-rules) for integers.
+var i18nPluralRules = map[string]PluralRule{
+	"ru": pluralRuleEastSlavic,
+	"uk": pluralRuleEastSlavic,
+	"be": pluralRuleEastSlavic,
+	"pl": pluralRulePolish,
+	"cs": pluralRuleCzech,
+	"sk": pluralRuleCzech,
+	"fr": pluralRuleZeroOne,
+	"pt": pluralRuleZeroOne,
+	"ar": pluralRuleArabic,
+	"ja": pluralRuleOther,
+	"zh": pluralRuleOther,
+	"ko": pluralRuleOther,
+	"vi": pluralRuleOther,
+	"th": pluralRuleOther,
+	"id": pluralRuleOther,
+	"ms": pluralRuleOther,
+}
+
+func pluralRuleOneOther(count int64) string {
+	if count == 1 {
+		return "one"
+	}
+	return "other"
+}
+
+func pluralRuleZeroOne(count int64) string {
+	if count == 0 || count == 1 {
+		return "one"
+	}
+	return "other"
+}
+
+func pluralRuleOther(count int64) string {
+	return "other"
+}
+
+func pluralRuleEastSlavic(count int64) string {
+	count = max(count, -count)
+	switch mod10, mod100 := count%10, count%100; {
+	case mod10 == 1 && mod100 != 11:
+		return "one"
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return "few"
+	default:
+		return "many"
+	}
+}
+
+func pluralRulePolish(count int64) string {
+	count = max(count, -count)
+	switch mod10, mod100 := count%10, count%100; {
+	case count == 1:
+		return "one"
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return "few"
+	default:
+		return "many"
+	}
+}
+
+func pluralRuleCzech(count int64) string {
+	switch {
+	case count == 1:
+		return "one"
+	case count >= 2 && count <= 4:
+		return "few"
+	default:
+		return "other"
+	}
+}
+
+func pluralRuleArabic(count int64) string {
+	count = max(count, -count)
+	switch mod100 := count % 100; {
+	case count == 0:
+		return "zero"
+	case count == 1:
+		return "one"
+	case count == 2:
+		return "two"
+	case mod100 >= 3 && mod100 <= 10:
+		return "few"
+	case mod100 >= 11:
+		return "many"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,108 @@
+package tg
+
+import (
+	"context"
+	"fmt"
+)
+
+// SendLongMessage sends text that does not fit MessageTextLimit as several messages, see Text.Split.
+// Text with ParseModeHTML or ParseModeMarkdownV2 is parsed first, so every part keeps its formatting.
+// ReplyMarkup is attached to the last part only, ReplyParameters and MessageEffectId to the first one.
+// On error messages sent so far are returned.
+func SendLongMessage(ctx context.Context, chatId int64, text string, opts ...*OptSendMessage) ([]*Message, error) {
+	opt := optsMerge(opts)
+	formatted, err := textParse(text, opt.ParseMode, opt.Entities)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := formatted.Split(MessageTextLimit)
+	if err != nil {
+		return nil, err
+	}
+	return sendLongText(ctx, chatId, parts, opt)
+}
+
+// SendLongCaption sends media with the caption cut to MessageCaptionLimit, the rest follows as text messages
+// replying to the media. send receives the caption part and the reply markup from opts, if nothing overflows
+// (otherwise the markup goes to the last text message).
+//
+// Example:
+//
+//	msgs, err := tg.SendLongCaption(ctx, chatId, caption, func(caption *tg.Text, markup tg.VariantInlineKeyboardMarkupReplyKeyboardMarkupReplyKeyboardRemoveForceReply) (*tg.Message, error) {
+//		return tg.SendPhoto(ctx, chatId, photo, &tg.OptSendPhoto{
+//			Caption:         caption.String(),
+//			CaptionEntities: caption.Entities(),
+//			ReplyMarkup:     markup,
+//		})
+//	}, &tg.OptSendMessage{ReplyMarkup: keyboard})
+func SendLongCaption(
+	ctx context.Context,
+	chatId int64,
+	caption *Text,
+	send func(caption *Text, markup VariantInlineKeyboardMarkupReplyKeyboardMarkupReplyKeyboardRemoveForceReply) (*Message, error),
+	opts ...*OptSendMessage,
+) ([]*Message, error) {
+	opt := optsMerge(opts)
+	head, tail := caption.splitFirst(MessageCaptionLimit)
+	parts := []*Text{head}
+	if tail != nil {
+		rest, err := tail.Split(MessageTextLimit)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, rest...)
+	}
+
+	markup := opt.ReplyMarkup
+	if len(parts) > 1 {
+		markup = nil
+	}
+	media, err := send(parts[0], markup)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 1 {
+		return []*Message{media}, nil
+	}
+
+	if opt.ReplyParameters == nil {
+		opt.ReplyParameters = &ReplyParameters{MessageId: media.MessageId}
+	}
+	opt.MessageEffectId = ""
+	msgs, err := sendLongText(ctx, chatId, parts[1:], opt)
+	return append([]*Message{media}, msgs...), err
+}
+
+func sendLongText(ctx context.Context, chatId int64, parts []*Text, opt *OptSendMessage) ([]*Message, error) {
+	result := make([]*Message, 0, len(parts))
+	for i, part := range parts {
+		partOpt := *opt
+		partOpt.ParseMode, partOpt.Entities = "", part.Entities()
+		if i != 0 {
+			partOpt.ReplyParameters, partOpt.MessageEffectId = nil, ""
+		}
+		if i != len(parts)-1 {
+			partOpt.ReplyMarkup = nil
+		}
+		msg, err := SendMessage(ctx, chatId, part.String(), &partOpt)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, msg)
+	}
+	return result, nil
+}
+
+// textParse turns text sent with a parse mode (or entities) into Text.
+func textParse(text string, parseMode string, entities []*MessageEntity) (*Text, error) {
+	switch parseMode {
+	case "":
+		return TextFromEntities(text, entities), nil
+	case ParseModeHTML:
+		return ParseHTML(text)
+	case ParseModeMarkdownV2:
+		return ParseMarkdownV2(text)
+	default:
+		return nil, fmt.Errorf("tg: parse mode %q is not supported, use %s or %s", parseMode, ParseModeHTML, ParseModeMarkdownV2)
+	}
+}
@@ -3,6 +3,7 @@ package tg
 import (
 	"cmp"
 	"context"
+	"io"
 	"slices"
 	"sync"
 	"time"
@@ -78,3 +79,10 @@ func (photo TelegramPhoto) DownloadTemp(ctx context.Context, dirAndPattern ...st
 	}
 	return (photo)[len(photo)-1].DownloadTemp(ctx, dirAndPattern...)
 }
+
+func (photo TelegramPhoto) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {
+	if len(photo) == 0 {
+		return nil, nil, &Error{Description: "no photo to download"}
+	}
+	return (photo)[len(photo)-1].DownloadReader(ctx)
+}
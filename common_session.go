@@ -0,0 +1,221 @@
+package tg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// SessionKeyFunc picks the session of the update, "" means the update has no session.
+type SessionKeyFunc func(upd *Update) string
+
+// OptSession configures SessionMiddleware.
+type OptSession struct {
+	// Storage of sessions, in memory by default.
+	Storage SessionStorage
+	// Key of the session, SessionKeyUser by default.
+	Key SessionKeyFunc
+}
+
+// SessionKeyUser keeps a session per user (across all chats).
+func SessionKeyUser(upd *Update) string {
+	if id := getSenderId(upd); id != 0 {
+		return "user:" + strconv.FormatInt(id, 10)
+	}
+	return ""
+}
+
+// SessionKeyChat keeps a session per chat (shared by all users of the chat).
+func SessionKeyChat(upd *Update) string {
+	if id := getChatId(upd); id != 0 {
+		return "chat:" + strconv.FormatInt(id, 10)
+	}
+	return ""
+}
+
+// SessionKeyChatUser keeps a session per user in every chat.
+func SessionKeyChatUser(upd *Update) string {
+	chatId, senderId := getChatId(upd), getSenderId(upd)
+	if chatId == 0 || senderId == 0 {
+		return ""
+	}
+	return fmt.Sprintf("chat_user:%d:%d", chatId, senderId)
+}
+
+// SessionMiddleware loads T of the update before handling (zero T for new sessions), handlers get it with Session.
+// The session is saved after the handler succeeded, if it was modified. Sessions are versioned: if the session
+// was saved by another update meanwhile, the handler gets ErrorSessionConflict (see OnError) and the changes are dropped.
+//
+// Example:
+//
+//	type Cart struct {
+//		Items []string `json:"items"`
+//	}
+//	storage, err := tg.NewSessionStorageJSON("sessions.json")
+//	...
+//	tg.NewFromEnv().
+//		Use(tg.SessionMiddleware[Cart](&tg.OptSession{Storage: storage})).
+//		Command("/add", func(ctx context.Context, upd *tg.Update) error {
+//			cart := tg.Session[Cart](ctx)
+//			cart.Items = append(cart.Items, "kitten")
+//			return tg.CommonTextReply(fmt.Sprintf("%d items", len(cart.Items)))(ctx, upd)
+//		}).
+//		Start()
+func SessionMiddleware[T any](opts ...*OptSession) MiddlewareFunc {
+	opt := at(opts, 0, &OptSession{})
+	storage, key := opt.Storage, opt.Key
+	if storage == nil {
+		storage = NewSessionStorageMemory()
+	}
+	if key == nil {
+		key = SessionKeyUser
+	}
+	contextKey := sessionContextKey[T]()
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, upd *Update) error {
+			sessionKey := key(upd)
+			if sessionKey == "" {
+				return next(ctx, upd)
+			}
+
+			data, version, err := storage.Load(sessionKey)
+			if err != nil {
+				return err
+			}
+			session := new(T)
+			if len(data) > 0 {
+				if err = json.Unmarshal(data, session); err != nil {
+					return fmt.Errorf("session: bad json of '%s' (%w)", sessionKey, err)
+				}
+			}
+			original, err := json.Marshal(session)
+			if err != nil {
+				return err
+			}
+
+			failed := &atomic.Bool{}
+			ctx = withFailureHook(context.WithValue(ctx, contextKey, session), func(error) { failed.Store(true) })
+			if err = next(ctx, upd); err != nil || failed.Load() {
+				return err
+			}
+
+			updated, err := json.Marshal(session)
+			if err != nil || bytes.Equal(original, updated) {
+				return err
+			}
+			return storage.Store(sessionKey, updated, version)
+		}
+	}
+}
+
+// Session returns the session of the update loaded by SessionMiddleware[T], nil if there is none.
+// Modify it in place, it is saved after the handler.
+func Session[T any](ctx context.Context) *T {
+	session, _ := ctx.Value(sessionContextKey[T]()).(*T)
+	return session
+}
+
+func sessionContextKey[T any]() string {
+	return ContextSession + ":" + reflect.TypeFor[T]().String()
+}
+
+// SessionStorage persists versioned sessions, implement it to keep sessions in your KV.
+type SessionStorage interface {
+	// Load returns nil data and 0 version for a new session.
+	Load(key string) (data []byte, version int64, err error)
+	// Store saves the data as version+1 if the stored version is still the version, ErrorSessionConflict otherwise.
+	Store(key string, data []byte, version int64) error
+	// Delete forgets the session, the next Load starts a new one.
+	Delete(key string) error
+}
+
+var (
+	_ SessionStorage = (*sessionStorageMemory)(nil)
+	_ SessionStorage = (*sessionStorageJSON)(nil)
+)
+
+type sessionRecord struct {
+	Version int64           `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func NewSessionStorageMemory() SessionStorage {
+	return &sessionStorageMemory{sessions: map[string]*sessionRecord{}}
+}
+
+type sessionStorageMemory struct {
+	mutex    sync.Mutex
+	sessions map[string]*sessionRecord
+}
+
+func (storage *sessionStorageMemory) Load(key string) ([]byte, int64, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	record, ok := storage.sessions[key]
+	if !ok {
+		return nil, 0, nil
+	}
+	return record.Data, record.Version, nil
+}
+
+func (storage *sessionStorageMemory) Store(key string, data []byte, version int64) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	return sessionsStore(storage.sessions, key, data, version)
+}
+
+func (storage *sessionStorageMemory) Delete(key string) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	delete(storage.sessions, key)
+	return nil
+}
+
+// NewSessionStorageJSON persists sessions in the JSON file, versions are checked as in the memory storage.
+func NewSessionStorageJSON(path string) (SessionStorage, error) {
+	store, err := newJSONFileStore[*sessionRecord]("session storage", path)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionStorageJSON{store: store}, nil
+}
+
+type sessionStorageJSON struct {
+	store *jsonFileStore[*sessionRecord]
+}
+
+func (storage *sessionStorageJSON) Load(key string) ([]byte, int64, error) {
+	record, ok := storage.store.Load(key)
+	if !ok {
+		return nil, 0, nil
+	}
+	return record.Data, record.Version, nil
+}
+
+func (storage *sessionStorageJSON) Store(key string, data []byte, version int64) error {
+	return storage.store.Update(func(sessions map[string]*sessionRecord) error {
+		return sessionsStore(sessions, key, data, version)
+	})
+}
+
+func (storage *sessionStorageJSON) Delete(key string) error {
+	return storage.store.Delete(key)
+}
+
+func sessionsStore(sessions map[string]*sessionRecord, key string, data []byte, version int64) error {
+	var current int64
+	if record, ok := sessions[key]; ok {
+		current = record.Version
+	}
+	if current != version {
+		return &ErrorSessionConflict{Key: key, Version: version, Current: current}
+	}
+	sessions[key] = &sessionRecord{Version: version + 1, Data: bytes.Clone(data)}
+	return nil
+}
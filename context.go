@@ -13,7 +13,18 @@ const (
 	ContextApiUrl           = contextPrefix + "api_url"
 	ContextExtraHeaders     = contextPrefix + "extra_headers"
 	ContextFileDownloadType = contextPrefix + "file_downloader"
+	ContextFileReaderType   = contextPrefix + "file_reader"
+	ContextLocalServer      = contextPrefix + "local_server"
+	ContextLocalPathMapping = contextPrefix + "local_path_mapping"
 	ContextScheduler        = contextPrefix + "scheduler"
+	ContextFileCache        = contextPrefix + "file_cache"
+	ContextDownloadMaxSize  = contextPrefix + "download_max_size"
+	ContextDownloadDedupe   = contextPrefix + "download_dedupe"
+	ContextI18n             = contextPrefix + "i18n"
+	ContextLocale           = contextPrefix + "locale"
+	ContextFSM              = contextPrefix + "fsm"
+	ContextSession          = contextPrefix + "session"
+	ContextCallbackSigner   = contextPrefix + "callback_signer"
 
 	contextPrefix = "kittenbark_"
 )
@@ -0,0 +1,677 @@
+package tg
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+)
+
+var (
+	encodingsHTMLAttribute = sync.OnceValue(func() *strings.Replacer {
+		return strings.NewReplacer(
+			"<", "&lt;",
+			">", "&gt;",
+			"&", "&amp;",
+			`"`, "&quot;",
+		)
+	})
+
+	encodingsMarkdownV2Code = sync.OnceValue(func() *strings.Replacer {
+		return strings.NewReplacer(
+			"`", "\\`",
+			"\\", "\\\\",
+		)
+	})
+
+	encodingsMarkdownV2Url = sync.OnceValue(func() *strings.Replacer {
+		return strings.NewReplacer(
+			")", "\\)",
+			"\\", "\\\\",
+		)
+	})
+)
+
+// TextFromEntities wraps already formatted text, i.e. Message.Text with Message.Entities.
+func TextFromEntities(text string, entities []*MessageEntity) *Text {
+	result := NewText().Plain(text)
+	for _, entity := range entities {
+		if entity != nil {
+			copied := *entity
+			result.entities = append(result.entities, &copied)
+		}
+	}
+	return result
+}
+
+// TextFormatted is either text or caption of the message with corresponding entities.
+func (impl *Message) TextFormatted() *Text {
+	switch {
+	case impl == nil:
+		return NewText()
+	case impl.Text != "":
+		return TextFromEntities(impl.Text, impl.Entities)
+	default:
+		return TextFromEntities(impl.Caption, impl.CaptionEntities)
+	}
+}
+
+// HTML renders the text with ParseModeHTML markup, entities detected by Telegram itself (mentions, urls and etc.) are kept as is.
+func (t *Text) HTML() string {
+	return t.render(textRendererHTML{})
+}
+
+// MarkdownV2 renders the text with ParseModeMarkdownV2 markup, entities detected by Telegram itself (mentions, urls and etc.) are kept as is.
+func (t *Text) MarkdownV2() string {
+	return t.render(&textRendererMarkdownV2{})
+}
+
+type textRenderer interface {
+	Open(out *strings.Builder, entity *MessageEntity)
+	Close(out *strings.Builder, entity *MessageEntity)
+	Text(out *strings.Builder, text string, active []*MessageEntity)
+}
+
+// render walks through the boundaries of entities, improperly nested (overlapping) entities are closed and reopened.
+func (t *Text) render(renderer textRenderer) string {
+	units := utf16.Encode([]rune(t.String()))
+	entities := slices.DeleteFunc(t.Entities(), func(entity *MessageEntity) bool {
+		return !textRenderable(entity) || entity.Length <= 0
+	})
+	slices.SortStableFunc(entities, textEntitiesCompare)
+
+	boundaries := []int64{0, int64(len(units))}
+	for _, entity := range entities {
+		boundaries = append(boundaries, entity.Offset, entity.Offset+entity.Length)
+	}
+	slices.Sort(boundaries)
+	boundaries = slices.Compact(boundaries)
+
+	out := &strings.Builder{}
+	stack := []*MessageEntity{}
+	for i, position := range boundaries {
+		closing := slices.IndexFunc(stack, func(entity *MessageEntity) bool { return entity.Offset+entity.Length <= position })
+		if closing >= 0 {
+			reopen := []*MessageEntity{}
+			for j := len(stack) - 1; j >= closing; j-- {
+				renderer.Close(out, stack[j])
+			}
+			for _, entity := range stack[closing:] {
+				if entity.Offset+entity.Length > position {
+					reopen = append(reopen, entity)
+				}
+			}
+			stack = stack[:closing]
+			for _, entity := range reopen {
+				renderer.Open(out, entity)
+				stack = append(stack, entity)
+			}
+		}
+		for _, entity := range entities {
+			if entity.Offset == position {
+				renderer.Open(out, entity)
+				stack = append(stack, entity)
+			}
+		}
+
+		if i+1 < len(boundaries) {
+			end := min(boundaries[i+1], int64(len(units)))
+			renderer.Text(out, string(utf16.Decode(units[min(position, end):end])), stack)
+		}
+	}
+	return out.String()
+}
+
+func textRenderable(entity *MessageEntity) bool {
+	switch entity.Type {
+	case EntityBold, EntityItalic, EntityUnderline, EntityStrikethrough, EntitySpoiler,
+		EntityBlockquote, EntityExpandableBlockquote, EntityCode, EntityPre,
+		EntityTextLink, EntityTextMention, EntityCustomEmoji:
+		return true
+	default:
+		return false
+	}
+}
+
+// normalized drops empty entities and sorts the rest with textEntitiesCompare.
+func (t *Text) normalized() *Text {
+	t.entities = slices.DeleteFunc(t.entities, func(entity *MessageEntity) bool { return entity.Length <= 0 })
+	slices.SortStableFunc(t.entities, textEntitiesCompare)
+	return t
+}
+
+// textEntitiesCompare orders entities by offset, outer entities go first.
+func textEntitiesCompare(a, b *MessageEntity) int {
+	return cmp.Or(cmp.Compare(a.Offset, b.Offset), cmp.Compare(b.Length, a.Length))
+}
+
+type textRendererHTML struct{}
+
+func (textRendererHTML) Open(out *strings.Builder, entity *MessageEntity) {
+	switch entity.Type {
+	case EntityBold:
+		out.WriteString("<b>")
+	case EntityItalic:
+		out.WriteString("<i>")
+	case EntityUnderline:
+		out.WriteString("<u>")
+	case EntityStrikethrough:
+		out.WriteString("<s>")
+	case EntitySpoiler:
+		out.WriteString("<tg-spoiler>")
+	case EntityBlockquote:
+		out.WriteString("<blockquote>")
+	case EntityExpandableBlockquote:
+		out.WriteString("<blockquote expandable>")
+	case EntityCode:
+		out.WriteString("<code>")
+	case EntityPre:
+		if entity.Language != "" {
+			out.WriteString(`<pre><code class="language-` + encodingsHTMLAttribute().Replace(entity.Language) + `">`)
+		} else {
+			out.WriteString("<pre>")
+		}
+	case EntityTextLink:
+		out.WriteString(`<a href="` + encodingsHTMLAttribute().Replace(entity.Url) + `">`)
+	case EntityTextMention:
+		out.WriteString(`<a href="tg://user?id=` + strconv.FormatInt(deref(entity.User).Id, 10) + `">`)
+	case EntityCustomEmoji:
+		out.WriteString(`<tg-emoji emoji-id="` + encodingsHTMLAttribute().Replace(entity.CustomEmojiId) + `">`)
+	}
+}
+
+func (textRendererHTML) Close(out *strings.Builder, entity *MessageEntity) {
+	switch entity.Type {
+	case EntityBold:
+		out.WriteString("</b>")
+	case EntityItalic:
+		out.WriteString("</i>")
+	case EntityUnderline:
+		out.WriteString("</u>")
+	case EntityStrikethrough:
+		out.WriteString("</s>")
+	case EntitySpoiler:
+		out.WriteString("</tg-spoiler>")
+	case EntityBlockquote, EntityExpandableBlockquote:
+		out.WriteString("</blockquote>")
+	case EntityCode:
+		out.WriteString("</code>")
+	case EntityPre:
+		if entity.Language != "" {
+			out.WriteString("</code></pre>")
+		} else {
+			out.WriteString("</pre>")
+		}
+	case EntityTextLink, EntityTextMention:
+		out.WriteString("</a>")
+	case EntityCustomEmoji:
+		out.WriteString("</tg-emoji>")
+	}
+}
+
+func (textRendererHTML) Text(out *strings.Builder, text string, active []*MessageEntity) {
+	out.WriteString(HTML(text))
+}
+
+type textRendererMarkdownV2 struct{}
+
+func (*textRendererMarkdownV2) Open(out *strings.Builder, entity *MessageEntity) {
+	switch entity.Type {
+	case EntityBold:
+		out.WriteString("*")
+	case EntityItalic:
+		markdownV2WriteUnderscores(out, "_")
+	case EntityUnderline:
+		markdownV2WriteUnderscores(out, "__")
+	case EntityStrikethrough:
+		out.WriteString("~")
+	case EntitySpoiler:
+		out.WriteString("||")
+	case EntityBlockquote:
+		out.WriteString(">")
+	case EntityExpandableBlockquote:
+		out.WriteString("**>")
+	case EntityCode:
+		out.WriteString("`")
+	case EntityPre:
+		out.WriteString("```" + entity.Language + "\n")
+	case EntityTextLink, EntityTextMention:
+		out.WriteString("[")
+	case EntityCustomEmoji:
+		out.WriteString("![")
+	}
+}
+
+func (*textRendererMarkdownV2) Close(out *strings.Builder, entity *MessageEntity) {
+	switch entity.Type {
+	case EntityBold:
+		out.WriteString("*")
+	case EntityItalic:
+		markdownV2WriteUnderscores(out, "_")
+	case EntityUnderline:
+		markdownV2WriteUnderscores(out, "__")
+	case EntityStrikethrough:
+		out.WriteString("~")
+	case EntitySpoiler:
+		out.WriteString("||")
+	case EntityBlockquote:
+	case EntityExpandableBlockquote:
+		out.WriteString("||")
+	case EntityCode:
+		out.WriteString("`")
+	case EntityPre:
+		out.WriteString("\n```")
+	case EntityTextLink:
+		out.WriteString("](" + encodingsMarkdownV2Url().Replace(entity.Url) + ")")
+	case EntityTextMention:
+		out.WriteString("](tg://user?id=" + strconv.FormatInt(deref(entity.User).Id, 10) + ")")
+	case EntityCustomEmoji:
+		out.WriteString("](tg://emoji?id=" + encodingsMarkdownV2Url().Replace(entity.CustomEmojiId) + ")")
+	}
+}
+
+func (*textRendererMarkdownV2) Text(out *strings.Builder, text string, active []*MessageEntity) {
+	code, quote := false, false
+	for _, entity := range active {
+		code = code || entity.Type == EntityCode || entity.Type == EntityPre
+		quote = quote || entity.Type == EntityBlockquote || entity.Type == EntityExpandableBlockquote
+	}
+	switch {
+	case code:
+		text = encodingsMarkdownV2Code().Replace(text)
+	default:
+		text = Md(text)
+	}
+	if quote {
+		text = strings.ReplaceAll(text, "\n", "\n>")
+	}
+	out.WriteString(text)
+}
+
+// markdownV2WriteUnderscores separates italic and underline markers with \r, as MarkdownV2 requires.
+func markdownV2WriteUnderscores(out *strings.Builder, marker string) {
+	if strings.HasSuffix(out.String(), "_") {
+		out.WriteString("\r")
+	}
+	out.WriteString(marker)
+}
+
+// ParseHTML parses ParseModeHTML markup into Text (supported tags: https://core.telegram.org/bots/api#html-style).
+func ParseHTML(markup string) (*Text, error) {
+	type openTag struct {
+		Name   string
+		Entity *MessageEntity
+	}
+
+	result := NewText()
+	stack := []*openTag{}
+	for len(markup) > 0 {
+		tagStart := strings.IndexByte(markup, '<')
+		if tagStart < 0 {
+			result.Plain(html.UnescapeString(markup))
+			break
+		}
+		result.Plain(html.UnescapeString(markup[:tagStart]))
+		tagEnd := strings.IndexByte(markup[tagStart:], '>')
+		if tagEnd < 0 {
+			return nil, fmt.Errorf("tg.ParseHTML: unclosed tag at %d", tagStart)
+		}
+		tag := markup[tagStart+1 : tagStart+tagEnd]
+		markup = markup[tagStart+tagEnd+1:]
+
+		if name, closing := strings.CutPrefix(tag, "/"); closing {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if len(stack) == 0 || stack[len(stack)-1].Name != name {
+				return nil, fmt.Errorf("tg.ParseHTML: unexpected closing tag </%s>", name)
+			}
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			top.Entity.Length = result.length - top.Entity.Offset
+			// <pre><code class="language-python"> is a pre with a language, not a code inside pre.
+			if top.Entity.Type == EntityCode && len(stack) > 0 && stack[len(stack)-1].Entity.Type == EntityPre {
+				stack[len(stack)-1].Entity.Language = top.Entity.Language
+				top.Entity.Length = 0
+			}
+			if top.Entity.Type != EntityPre {
+				top.Entity.Language = ""
+			}
+			continue
+		}
+
+		name, attributes := htmlParseTag(tag)
+		entity := &MessageEntity{Offset: result.length}
+		switch name {
+		case "b", "strong":
+			entity.Type = EntityBold
+		case "i", "em":
+			entity.Type = EntityItalic
+		case "u", "ins":
+			entity.Type = EntityUnderline
+		case "s", "strike", "del":
+			entity.Type = EntityStrikethrough
+		case "tg-spoiler":
+			entity.Type = EntitySpoiler
+		case "span":
+			if attributes["class"] != "tg-spoiler" {
+				return nil, fmt.Errorf("tg.ParseHTML: unsupported <span class=%q>", attributes["class"])
+			}
+			entity.Type = EntitySpoiler
+		case "code":
+			entity.Type = EntityCode
+			entity.Language, _ = strings.CutPrefix(attributes["class"], "language-")
+		case "pre":
+			entity.Type = EntityPre
+		case "blockquote":
+			entity.Type = EntityBlockquote
+			if _, ok := attributes["expandable"]; ok {
+				entity.Type = EntityExpandableBlockquote
+			}
+		case "a":
+			href := attributes["href"]
+			if id, ok := strings.CutPrefix(href, "tg://user?id="); ok {
+				userId, err := strconv.ParseInt(id, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("tg.ParseHTML: bad user id in %q", href)
+				}
+				entity.Type, entity.User = EntityTextMention, &User{Id: userId}
+			} else {
+				entity.Type, entity.Url = EntityTextLink, href
+			}
+		case "tg-emoji":
+			entity.Type, entity.CustomEmojiId = EntityCustomEmoji, attributes["emoji-id"]
+		default:
+			return nil, fmt.Errorf("tg.ParseHTML: unsupported tag <%s>", name)
+		}
+		// Entities are added in the order of opening, so the outer one goes first when ranges are equal.
+		stack = append(stack, &openTag{Name: name, Entity: entity})
+		result.entities = append(result.entities, entity)
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("tg.ParseHTML: unclosed tag <%s>", stack[len(stack)-1].Name)
+	}
+	return result.normalized(), nil
+}
+
+// htmlParseTag parses `name key="value" flag` into the lowercase name and attributes.
+func htmlParseTag(tag string) (string, map[string]string) {
+	tag = strings.TrimSpace(strings.TrimSuffix(tag, "/"))
+	name, rest, _ := strings.Cut(tag, " ")
+	attributes := map[string]string{}
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		end := strings.IndexAny(rest, "= ")
+		if end < 0 || rest[end] == ' ' {
+			key := rest
+			if end >= 0 {
+				key, rest = rest[:end], rest[end:]
+			} else {
+				rest = ""
+			}
+			attributes[strings.ToLower(key)] = ""
+			continue
+		}
+
+		key, value := strings.ToLower(rest[:end]), strings.TrimSpace(rest[end+1:])
+		switch {
+		case value == "":
+			rest = ""
+		case value[0] == '"' || value[0] == '\'':
+			closing := strings.IndexByte(value[1:], value[0])
+			if closing < 0 {
+				closing = len(value) - 1
+			}
+			rest, value = value[min(closing+2, len(value)):], value[1:closing+1]
+		default:
+			space := strings.IndexByte(value, ' ')
+			if space < 0 {
+				space = len(value)
+			}
+			rest, value = value[space:], value[:space]
+		}
+		attributes[key] = html.UnescapeString(value)
+	}
+	return strings.ToLower(name), attributes
+}
+
+// ParseMarkdownV2 parses ParseModeMarkdownV2 markup into Text (syntax: https://core.telegram.org/bots/api#markdownv2-style).
+func ParseMarkdownV2(markup string) (*Text, error) {
+	parser := &markdownV2Parser{input: []rune(markup), result: NewText(), open: map[string]*MessageEntity{}}
+	if err := parser.parse(); err != nil {
+		return nil, err
+	}
+	return parser.result.normalized(), nil
+}
+
+type markdownV2Parser struct {
+	input  []rune
+	pos    int
+	result *Text
+	open   map[string]*MessageEntity
+	links  []*MessageEntity
+	quote  *MessageEntity
+}
+
+func (parser *markdownV2Parser) parse() error {
+	lineStart := true
+	for parser.pos < len(parser.input) {
+		if lineStart {
+			parser.parseLineStart()
+		}
+		lineStart = false
+
+		char := parser.input[parser.pos]
+		switch {
+		case char == '\\' && parser.pos+1 < len(parser.input):
+			parser.result.Plain(string(parser.input[parser.pos+1]))
+			parser.pos += 2
+		case char == '\n':
+			parser.result.Plain("\n")
+			parser.pos++
+			lineStart = true
+		case char == '\r' && parser.pos > 0 && parser.input[parser.pos-1] == '_':
+			parser.pos++
+		case parser.hasPrefix("```"):
+			if err := parser.parsePre(); err != nil {
+				return err
+			}
+		case char == '`':
+			if err := parser.parseCode(); err != nil {
+				return err
+			}
+		case parser.hasPrefix("||"):
+			parser.pos += 2
+			if parser.quote != nil && parser.quote.Type == EntityExpandableBlockquote && parser.open["||"] == nil &&
+				(parser.pos == len(parser.input) || parser.input[parser.pos] == '\n') {
+				parser.closeQuote()
+				continue
+			}
+			parser.toggle("||", EntitySpoiler)
+		case parser.hasPrefix("__"):
+			parser.pos += 2
+			parser.toggle("__", EntityUnderline)
+		case char == '_':
+			parser.pos++
+			parser.toggle("_", EntityItalic)
+		case char == '*':
+			parser.pos++
+			parser.toggle("*", EntityBold)
+		case char == '~':
+			parser.pos++
+			parser.toggle("~", EntityStrikethrough)
+		case parser.hasPrefix("!["):
+			parser.pos += 2
+			parser.links = append(parser.links, parser.openEntity(EntityCustomEmoji))
+		case char == '[':
+			parser.pos++
+			parser.links = append(parser.links, parser.openEntity(EntityTextLink))
+		case char == ']' && len(parser.links) > 0:
+			if err := parser.parseLinkEnd(); err != nil {
+				return err
+			}
+		default:
+			parser.result.Plain(string(char))
+			parser.pos++
+		}
+	}
+
+	if parser.quote != nil {
+		parser.closeQuote()
+	}
+	for marker := range parser.open {
+		return fmt.Errorf("tg.ParseMarkdownV2: unclosed '%s'", marker)
+	}
+	if len(parser.links) > 0 {
+		return fmt.Errorf("tg.ParseMarkdownV2: unclosed '['")
+	}
+	return nil
+}
+
+// parseLineStart handles blockquotes, they are the only line based entities.
+func (parser *markdownV2Parser) parseLineStart() {
+	expandable := parser.hasPrefix("**>")
+	quoted := expandable || parser.hasPrefix(">")
+	if parser.quote != nil && (!quoted || expandable) {
+		// The previous line was the last one of the quote, the new line character is not a part of it.
+		parser.quote.Length = parser.result.length - 1 - parser.quote.Offset
+		parser.quote = nil
+	}
+	switch {
+	case expandable:
+		parser.pos += 3
+		parser.quote = parser.openEntity(EntityExpandableBlockquote)
+	case quoted:
+		parser.pos++
+		if parser.quote == nil {
+			parser.quote = parser.openEntity(EntityBlockquote)
+		}
+	}
+}
+
+func (parser *markdownV2Parser) closeQuote() {
+	parser.quote.Length = parser.result.length - parser.quote.Offset
+	parser.quote = nil
+}
+
+func (parser *markdownV2Parser) toggle(marker string, entityType string) {
+	if entity, ok := parser.open[marker]; ok {
+		delete(parser.open, marker)
+		entity.Length = parser.result.length - entity.Offset
+		return
+	}
+	parser.open[marker] = parser.openEntity(entityType)
+}
+
+func (parser *markdownV2Parser) parsePre() error {
+	parser.pos += 3
+	language := []rune{}
+	for parser.pos < len(parser.input) && parser.input[parser.pos] != '\n' && !parser.hasPrefix("```") {
+		language = append(language, parser.input[parser.pos])
+		parser.pos++
+	}
+	if parser.pos < len(parser.input) && parser.input[parser.pos] == '\n' {
+		parser.pos++
+	} else {
+		// ```code``` on a single line has no language.
+		parser.pos -= len(language)
+		language = nil
+	}
+
+	entity := parser.openEntity(EntityPre)
+	entity.Language = string(language)
+	content, err := parser.readCode("```")
+	if err != nil {
+		return err
+	}
+	content = strings.TrimSuffix(content, "\n")
+	parser.result.Plain(content)
+	entity.Length = parser.result.length - entity.Offset
+	return nil
+}
+
+func (parser *markdownV2Parser) parseCode() error {
+	parser.pos++
+	entity := parser.openEntity(EntityCode)
+	content, err := parser.readCode("`")
+	if err != nil {
+		return err
+	}
+	parser.result.Plain(content)
+	entity.Length = parser.result.length - entity.Offset
+	return nil
+}
+
+func (parser *markdownV2Parser) readCode(end string) (string, error) {
+	content := strings.Builder{}
+	for parser.pos < len(parser.input) {
+		switch {
+		case parser.input[parser.pos] == '\\' && parser.pos+1 < len(parser.input):
+			content.WriteRune(parser.input[parser.pos+1])
+			parser.pos += 2
+		case parser.hasPrefix(end):
+			parser.pos += len(end)
+			return content.String(), nil
+		default:
+			content.WriteRune(parser.input[parser.pos])
+			parser.pos++
+		}
+	}
+	return "", fmt.Errorf("tg.ParseMarkdownV2: unclosed '%s'", end)
+}
+
+func (parser *markdownV2Parser) parseLinkEnd() error {
+	parser.pos++
+	if !parser.hasPrefix("(") {
+		return fmt.Errorf("tg.ParseMarkdownV2: expected '(' after ']' at %d", parser.pos)
+	}
+	parser.pos++
+	url := strings.Builder{}
+	for {
+		if parser.pos >= len(parser.input) {
+			return fmt.Errorf("tg.ParseMarkdownV2: unclosed '('")
+		}
+		char := parser.input[parser.pos]
+		parser.pos++
+		if char == '\\' && parser.pos < len(parser.input) {
+			url.WriteRune(parser.input[parser.pos])
+			parser.pos++
+			continue
+		}
+		if char == ')' {
+			break
+		}
+		url.WriteRune(char)
+	}
+
+	entity := parser.links[len(parser.links)-1]
+	parser.links = parser.links[:len(parser.links)-1]
+	entity.Length = parser.result.length - entity.Offset
+	link := url.String()
+	switch {
+	case entity.Type == EntityCustomEmoji:
+		entity.CustomEmojiId = strings.TrimPrefix(link, "tg://emoji?id=")
+	case strings.HasPrefix(link, "tg://user?id="):
+		userId, err := strconv.ParseInt(strings.TrimPrefix(link, "tg://user?id="), 10, 64)
+		if err != nil {
+			return fmt.Errorf("tg.ParseMarkdownV2: bad user id in %q", link)
+		}
+		entity.Type, entity.User = EntityTextMention, &User{Id: userId}
+	default:
+		entity.Url = link
+	}
+	return nil
+}
+
+// openEntity adds an entity in the order of opening, so the outer one goes first when ranges are equal.
+func (parser *markdownV2Parser) openEntity(entityType string) *MessageEntity {
+	entity := &MessageEntity{Type: entityType, Offset: parser.result.length}
+	parser.result.entities = append(parser.result.entities, entity)
+	return entity
+}
+
+func (parser *markdownV2Parser) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(string(parser.input[parser.pos:min(parser.pos+len(prefix), len(parser.input))]), prefix)
+}
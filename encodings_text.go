@@ -0,0 +1,189 @@
+package tg
+
+import (
+	"context"
+	"strings"
+	"unicode/utf16"
+)
+
+// MessageEntity types, check https://core.telegram.org/bots/api#messageentity.
+const (
+	EntityMention              = "mention"
+	EntityHashtag              = "hashtag"
+	EntityCashtag              = "cashtag"
+	EntityBotCommand           = "bot_command"
+	EntityUrl                  = "url"
+	EntityEmail                = "email"
+	EntityPhoneNumber          = "phone_number"
+	EntityBold                 = "bold"
+	EntityItalic               = "italic"
+	EntityUnderline            = "underline"
+	EntityStrikethrough        = "strikethrough"
+	EntitySpoiler              = "spoiler"
+	EntityBlockquote           = "blockquote"
+	EntityExpandableBlockquote = "expandable_blockquote"
+	EntityCode                 = "code"
+	EntityPre                  = "pre"
+	EntityTextLink             = "text_link"
+	EntityTextMention          = "text_mention"
+	EntityCustomEmoji          = "custom_emoji"
+)
+
+// Text builds a message text together with its entities, so no parse mode and no escaping is involved.
+// Offsets are counted in UTF-16 code units, as Telegram expects.
+//
+// Example:
+//
+//	text := tg.NewText().
+//		Bold("hii").Plain(" mom, check ").Link("this", "https://kittenbark.com").Line().
+//		Wrap(&tg.MessageEntity{Type: tg.EntityItalic}, tg.NewText().Plain("italic ").Bold("and bold"))
+//	_, err := tg.SendText(ctx, chatId, text)
+//	// or as a caption
+//	_, err = tg.SendPhoto(ctx, chatId, photo, &tg.OptSendPhoto{Caption: text.String(), CaptionEntities: text.Entities()})
+type Text struct {
+	text     strings.Builder
+	entities []*MessageEntity
+	length   int64
+}
+
+func NewText() *Text {
+	return &Text{}
+}
+
+// Plain appends text without any formatting.
+func (t *Text) Plain(text string) *Text {
+	t.text.WriteString(text)
+	t.length += utf16Len(text)
+	return t
+}
+
+// Line appends a new line.
+func (t *Text) Line() *Text {
+	return t.Plain("\n")
+}
+
+// Entity appends text covered by the entity, Offset and Length are calculated by Text.
+func (t *Text) Entity(entity *MessageEntity, text string) *Text {
+	return t.Wrap(entity, NewText().Plain(text))
+}
+
+// Wrap appends inner text covering it by the entity, this is the way to nest entities.
+func (t *Text) Wrap(entity *MessageEntity, inner *Text) *Text {
+	start := t.length
+	outer := *entity
+	outer.Offset, outer.Length = start, inner.length
+
+	if outer.Length > 0 {
+		t.entities = append(t.entities, &outer)
+	}
+	return t.Append(inner)
+}
+
+// Append appends text with its entities.
+func (t *Text) Append(inner *Text) *Text {
+	start := t.length
+	for _, entity := range inner.entities {
+		shifted := *entity
+		shifted.Offset += start
+		t.entities = append(t.entities, &shifted)
+	}
+	t.text.WriteString(inner.text.String())
+	t.length += inner.length
+	return t
+}
+
+func (t *Text) Bold(text string) *Text { return t.Entity(&MessageEntity{Type: EntityBold}, text) }
+
+func (t *Text) Italic(text string) *Text { return t.Entity(&MessageEntity{Type: EntityItalic}, text) }
+
+func (t *Text) Underline(text string) *Text {
+	return t.Entity(&MessageEntity{Type: EntityUnderline}, text)
+}
+
+func (t *Text) Strikethrough(text string) *Text {
+	return t.Entity(&MessageEntity{Type: EntityStrikethrough}, text)
+}
+
+func (t *Text) Spoiler(text string) *Text { return t.Entity(&MessageEntity{Type: EntitySpoiler}, text) }
+
+func (t *Text) Code(text string) *Text { return t.Entity(&MessageEntity{Type: EntityCode}, text) }
+
+// Pre is a code block, language is optional.
+func (t *Text) Pre(text string, language ...string) *Text {
+	return t.Entity(&MessageEntity{Type: EntityPre, Language: at(language, 0, "")}, text)
+}
+
+func (t *Text) Link(text string, url string) *Text {
+	return t.Entity(&MessageEntity{Type: EntityTextLink, Url: url}, text)
+}
+
+// Mention mentions a user even without a username.
+func (t *Text) Mention(text string, user *User) *Text {
+	return t.Entity(&MessageEntity{Type: EntityTextMention, User: user}, text)
+}
+
+// CustomEmoji requires emoji as a fallback text, i.e. CustomEmoji("👍", "5368324170671202286").
+func (t *Text) CustomEmoji(emoji string, customEmojiId string) *Text {
+	return t.Entity(&MessageEntity{Type: EntityCustomEmoji, CustomEmojiId: customEmojiId}, emoji)
+}
+
+func (t *Text) Blockquote(text string) *Text {
+	return t.Entity(&MessageEntity{Type: EntityBlockquote}, text)
+}
+
+func (t *Text) ExpandableBlockquote(text string) *Text {
+	return t.Entity(&MessageEntity{Type: EntityExpandableBlockquote}, text)
+}
+
+// String is the plain text of the message.
+func (t *Text) String() string {
+	return t.text.String()
+}
+
+// Entities are copies, modifying them does not affect Text.
+func (t *Text) Entities() []*MessageEntity {
+	result := make([]*MessageEntity, len(t.entities))
+	for i, entity := range t.entities {
+		copied := *entity
+		result[i] = &copied
+	}
+	return result
+}
+
+// Len is the length in UTF-16 code units (Telegram limits are counted in them).
+func (t *Text) Len() int64 {
+	return t.length
+}
+
+// SendText sends Text with its entities, opts are applied as usual (ParseMode must be empty).
+func SendText(ctx context.Context, chatId int64, text *Text, opts ...*OptSendMessage) (*Message, error) {
+	return SendMessage(ctx, chatId, text.String(), append([]*OptSendMessage{{Entities: text.Entities()}}, opts...)...)
+}
+
+func utf16Len(text string) int64 {
+	length := int64(0)
+	for _, r := range text {
+		length += int64(utf16.RuneLen(r))
+	}
+	return length
+}
+
+// utf16Substring returns text[offset:offset+length] with offset and length counted in UTF-16 code units,
+// out of range parts are ignored.
+func utf16Substring(text string, offset int64, length int64) string {
+	start, end, position := len(text), len(text), int64(0)
+	for i, r := range text {
+		if position >= offset && start == len(text) {
+			start = i
+		}
+		if position >= offset+length {
+			end = i
+			break
+		}
+		position += int64(utf16.RuneLen(r))
+	}
+	if start > end {
+		return ""
+	}
+	return text[start:end]
+}
@@ -0,0 +1,124 @@
+package tg
+
+import (
+	"fmt"
+	"slices"
+	"unicode/utf16"
+)
+
+// Telegram limits, counted in UTF-16 code units.
+const (
+	MessageTextLimit    = 4096
+	MessageCaptionLimit = 1024
+)
+
+// textSplitSeparators are boundaries in order of preference, Keep is the part of separator left in the previous chunk,
+// the rest of it is dropped (i.e. no message starts with a new line or a space).
+var textSplitSeparators = []struct {
+	Separator string
+	Keep      int
+}{
+	{"\n\n", 0},
+	{"\n", 0},
+	{". ", 1},
+	{"! ", 1},
+	{"? ", 1},
+	{"; ", 1},
+	{" ", 0},
+}
+
+// Split splits the text into parts of at most limit UTF-16 code units, preferring paragraph, line, sentence and word
+// boundaries. Entities crossing a boundary are split too, so formatting continues in the next part; mentions, urls,
+// custom emojis and other entities detected by Telegram are never cut in half.
+// A part is never empty: with a limit smaller than a code point (e.g. 1 and an emoji) the whole code point is taken.
+func (t *Text) Split(limit int64) ([]*Text, error) {
+	if limit < 1 {
+		return nil, fmt.Errorf("tg: split limit must be positive, got %d", limit)
+	}
+	units := utf16.Encode([]rune(t.String()))
+	parts := []*Text{}
+	for start := int64(0); ; {
+		if int64(len(units))-start <= limit {
+			if start < int64(len(units)) || len(parts) == 0 {
+				parts = append(parts, t.slice(units, start, int64(len(units))))
+			}
+			return parts, nil
+		}
+		end, next := t.splitPosition(units, start, limit)
+		parts = append(parts, t.slice(units, start, end))
+		start = next
+	}
+}
+
+// splitFirst cuts off the first part as Split does, tail is nil if the text fits the limit.
+func (t *Text) splitFirst(limit int64) (head *Text, tail *Text) {
+	units := utf16.Encode([]rune(t.String()))
+	if int64(len(units)) <= limit {
+		return t.slice(units, 0, int64(len(units))), nil
+	}
+	end, next := t.splitPosition(units, 0, limit)
+	return t.slice(units, 0, end), t.slice(units, next, int64(len(units)))
+}
+
+// splitPosition finds the end of the chunk starting at start and the beginning of the next one.
+func (t *Text) splitPosition(units []uint16, start int64, limit int64) (end int64, next int64) {
+	for _, separator := range textSplitSeparators {
+		encoded := utf16.Encode([]rune(separator.Separator))
+		// Tiny chunks are worse than cuts in the middle of a sentence.
+		for position := start + limit - int64(separator.Keep); position >= start+limit/2; position-- {
+			if position+int64(len(encoded)) > int64(len(units)) || !slices.Equal(units[position:position+int64(len(encoded))], encoded) {
+				continue
+			}
+			end = position + int64(separator.Keep)
+			if end > start && !t.insideAtomic(end) {
+				return end, position + int64(len(encoded))
+			}
+		}
+	}
+
+	end = start + limit
+	if utf16.IsSurrogate(rune(units[end-1])) && units[end-1] < 0xDC00 {
+		end--
+	}
+	if end <= start {
+		// The limit is less than a surrogate pair, the chunk still has to move forward.
+		end = start + 2
+	}
+	for _, entity := range t.entities {
+		if textSplitAtomic(entity) && entity.Offset > start && entity.Offset < end && end < entity.Offset+entity.Length {
+			end = entity.Offset
+		}
+	}
+	return end, end
+}
+
+func (t *Text) insideAtomic(position int64) bool {
+	return slices.ContainsFunc(t.entities, func(entity *MessageEntity) bool {
+		return textSplitAtomic(entity) && entity.Offset < position && position < entity.Offset+entity.Length
+	})
+}
+
+func textSplitAtomic(entity *MessageEntity) bool {
+	switch entity.Type {
+	case EntityMention, EntityHashtag, EntityCashtag, EntityBotCommand, EntityUrl, EntityEmail, EntityPhoneNumber,
+		EntityTextMention, EntityCustomEmoji:
+		return true
+	default:
+		return false
+	}
+}
+
+// slice is a part of the text [from, to) with clipped entities, units is the UTF-16 encoded text.
+func (t *Text) slice(units []uint16, from int64, to int64) *Text {
+	result := NewText().Plain(string(utf16.Decode(units[from:to])))
+	for _, entity := range t.entities {
+		clipped := *entity
+		clipped.Offset, clipped.Length = max(entity.Offset, from), min(entity.Offset+entity.Length, to)
+		clipped.Length -= clipped.Offset
+		clipped.Offset -= from
+		if clipped.Length > 0 {
+			result.entities = append(result.entities, &clipped)
+		}
+	}
+	return result
+}
@@ -46,6 +46,91 @@ func IsTooManyRequests(err error) bool {
 	return errors.As(err, &errErrorTooManyRequests)
 }
 
+// ErrorDownloadTooLarge is returned if the file exceeds WithDownloadMaxSize/Config.DownloadMaxSize.
+type ErrorDownloadTooLarge struct {
+	FileId  string
+	Size    int64
+	MaxSize int64
+}
+
+func (err *ErrorDownloadTooLarge) Error() string {
+	return fmt.Sprintf("telegram download file: too large %d > %d bytes (%s)", err.Size, err.MaxSize, err.FileId)
+}
+
+// ErrorDownloadTruncated is returned if the downloaded file size differs from File.FileSize.
+type ErrorDownloadTruncated struct {
+	FileId   string
+	Expected int64
+	Actual   int64
+}
+
+func (err *ErrorDownloadTruncated) Error() string {
+	return fmt.Sprintf("telegram download file: expected %d bytes, got %d (%s)", err.Expected, err.Actual, err.FileId)
+}
+
+// ErrorCommandArgs is a user's mistake in arguments of CommandDefinition, Reason is replied to the user.
+type ErrorCommandArgs struct {
+	Command string
+	Arg     string
+	Reason  string
+}
+
+func (err *ErrorCommandArgs) Error() string {
+	return fmt.Sprintf("telegram command %s: %s", err.Command, err.Reason)
+}
+
+// ErrorAskTimeout is returned by Ask if nobody answered in time.
+type ErrorAskTimeout struct {
+	Timeout time.Duration
+}
+
+func (err *ErrorAskTimeout) Error() string {
+	return fmt.Sprintf("telegram ask: no answer in %s", err.Timeout)
+}
+
+// ErrorAskCanceled is returned by Ask if the user answered with one of OptAsk.CancelCommands.
+type ErrorAskCanceled struct {
+	Command string
+}
+
+func (err *ErrorAskCanceled) Error() string {
+	return fmt.Sprintf("telegram ask: canceled with %s", err.Command)
+}
+
+// ErrorSessionConflict is returned by SessionStorage.Store if the session was saved by another update meanwhile.
+type ErrorSessionConflict struct {
+	Key     string
+	Version int64
+	Current int64
+}
+
+func (err *ErrorSessionConflict) Error() string {
+	return fmt.Sprintf("telegram session %s: version conflict %d != %d", err.Key, err.Version, err.Current)
+}
+
+// ErrorCallbackDataTooLong is returned by Keyboard.TryBuild if the data of a button does not fit into the callback data,
+// set Keyboard.Storage to keep long data on the server side.
+type ErrorCallbackDataTooLong struct {
+	Data  string
+	Size  int
+	Limit int
+}
+
+func (err *ErrorCallbackDataTooLong) Error() string {
+	return fmt.Sprintf("telegram callback data: too long %d > %d bytes (%q)", err.Size, err.Limit, err.Data)
+}
+
+// ErrorCallbackDataInvalid is reported if signed callback data is tampered or expired (see CallbackSigner).
+type ErrorCallbackDataInvalid struct {
+	Data    string
+	Reason  string
+	Expired bool
+}
+
+func (err *ErrorCallbackDataInvalid) Error() string {
+	return fmt.Sprintf("telegram callback data: %s (%q)", err.Reason, err.Data)
+}
+
 func IsApiError(err error) bool {
 	var errError *Error
 	var errErrorTooManyRequests *ErrorTooManyRequests
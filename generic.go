@@ -57,11 +57,49 @@ func GenericRequest[Request any, Result any](ctx context.Context, method string,
 		err = newTelegramError(httpResult.ErrorCode, httpResult.Description, httpResult.Parameters)
 		return
 	}
+	requestSucceeded(ctx, method)
 
 	result = httpResult.Result
 	return
 }
 
+// requestHook observes successful requests made with the context, e.g. AutoAnswerCallbacks looks for answers.
+type requestHook func(method string)
+
+const contextRequestHooks = contextPrefix + "request_hooks"
+
+// withRequestHook adds the hook to the hooks already installed in the context.
+func withRequestHook(ctx context.Context, hook requestHook) context.Context {
+	hooks, _ := ctx.Value(contextRequestHooks).([]requestHook)
+	return context.WithValue(ctx, contextRequestHooks, append(slices.Clip(hooks), hook))
+}
+
+func requestSucceeded(ctx context.Context, method string) {
+	hooks, _ := ctx.Value(contextRequestHooks).([]requestHook)
+	for _, hook := range hooks {
+		hook(method)
+	}
+}
+
+// failureHook learns about handler errors, which the pipeline reports to OnError instead of returning them
+// to middlewares, e.g. SessionMiddleware doesn't save the session of a failed handler.
+type failureHook func(err error)
+
+const contextFailureHooks = contextPrefix + "failure_hooks"
+
+// withFailureHook adds the hook to the hooks already installed in the context.
+func withFailureHook(ctx context.Context, hook failureHook) context.Context {
+	hooks, _ := ctx.Value(contextFailureHooks).([]failureHook)
+	return context.WithValue(ctx, contextFailureHooks, append(slices.Clip(hooks), hook))
+}
+
+func handlerFailed(ctx context.Context, err error) {
+	hooks, _ := ctx.Value(contextFailureHooks).([]failureHook)
+	for _, hook := range hooks {
+		hook(err)
+	}
+}
+
 func newTelegramError(code int, description string, parameters map[string]interface{}) error {
 	switch code {
 	case http.StatusTooManyRequests:
@@ -137,3 +175,21 @@ func at[T any](list []T, pos int, defaultValue T) T {
 	}
 	return list[pos]
 }
+
+// optsMerge merges Opt* structs the way generated methods do: the last non-empty value of each field wins.
+func optsMerge[T any](opts []*T) *T {
+	result := new(T)
+	merged := reflect.ValueOf(result).Elem()
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		value := reflect.ValueOf(opt).Elem()
+		for i := range value.NumField() {
+			if !isEmptyValue(value.Field(i)) {
+				merged.Field(i).Set(value.Field(i))
+			}
+		}
+	}
+	return result
+}
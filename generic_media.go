@@ -14,6 +14,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // InputFile is either:
@@ -95,6 +96,10 @@ func GenericRequestMultipart[Request any, Result any](ctx context.Context, metho
 	}
 	url := fmt.Sprintf("%s/bot%s/%s", getOrDefault(ctx, ContextApiUrl, DefaultTelegramApiUrl), token, method)
 
+	cached := fileCacheSubstitute(ctx, request)
+	defer func() { fileCacheRemember(ctx, cached, result, err) }()
+	localServerSubstitute(ctx, request)
+
 	body, contentType := requestMultipartPreparePipes[Request](defaults(request))
 	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
 	if err != nil {
@@ -125,10 +130,74 @@ func GenericRequestMultipart[Request any, Result any](ctx context.Context, metho
 		err = newTelegramError(httpResult.ErrorCode, httpResult.Description, httpResult.Parameters)
 		return
 	}
+	requestSucceeded(ctx, method)
 
 	return httpResult.Result, nil
 }
 
+// requestReplaceInputFiles replaces every InputFile of the request (including ones of InputMedia) with fn results,
+// field is the json name, index is the position in a media group (-1 otherwise).
+// User's InputMedia are copied on change, not modified.
+func requestReplaceInputFiles[Request any](request *Request, fn func(field string, index int, file InputFile) InputFile) {
+	requestValue := reflect.Indirect(reflect.ValueOf(request))
+	requestType := requestValue.Type()
+	for i := range requestValue.NumField() {
+		fieldName, _, _ := strings.Cut(requestType.Field(i).Tag.Get("json"), ",")
+		if fieldName == "-" {
+			continue
+		}
+		fieldValue := requestValue.Field(i)
+		switch field := fieldValue.Interface().(type) {
+		case InputFile:
+			fieldValue.Set(reflect.ValueOf(fn(fieldName, -1, field)))
+
+		case InputMedia:
+			fieldValue.Set(reflect.ValueOf(inputMediaReplaceInputFiles(field, -1, fn)))
+
+		case []InputMedia:
+			medias := make([]InputMedia, len(field))
+			for j, media := range field {
+				medias[j] = inputMediaReplaceInputFiles(media, j, fn)
+			}
+			fieldValue.Set(reflect.ValueOf(medias))
+		}
+	}
+}
+
+func inputMediaReplaceInputFiles(media InputMedia, index int, fn func(field string, index int, file InputFile) InputFile) InputMedia {
+	mediaValue := reflect.ValueOf(media)
+	if mediaValue.Kind() != reflect.Pointer || mediaValue.IsNil() {
+		return media
+	}
+
+	var copied reflect.Value
+	mediaType := mediaValue.Elem().Type()
+	for i := range mediaType.NumField() {
+		fieldName, _, _ := strings.Cut(mediaType.Field(i).Tag.Get("json"), ",")
+		if fieldName == "-" {
+			continue
+		}
+		file, ok := mediaValue.Elem().Field(i).Interface().(InputFile)
+		if !ok {
+			continue
+		}
+		substitute := fn(fieldName, index, file)
+		if substitute == file {
+			continue
+		}
+		if !copied.IsValid() {
+			copied = reflect.New(mediaType)
+			copied.Elem().Set(mediaValue.Elem())
+		}
+		copied.Elem().Field(i).Set(reflect.ValueOf(substitute))
+	}
+
+	if !copied.IsValid() {
+		return media
+	}
+	return copied.Interface().(InputMedia)
+}
+
 func multipartWritePipesInputMedia(media InputMedia, multipart *multipart.Writer) (string, error) {
 	result := map[string]any{}
 
@@ -281,34 +350,80 @@ func GenericDownload(ctx context.Context, path string, fileId string) error {
 	if err != nil {
 		return err
 	}
+	return downloadFile(ctx, file, path)
+}
 
-	return getOrDefault[downloaderFunc](ctx, ContextFileDownloadType, fileDownloadClassic)(ctx, file, path)
+func downloadFile(ctx context.Context, file *File, path string) error {
+	if err := downloadCheckSize(ctx, file, file.FileSize); err != nil {
+		return err
+	}
+
+	dedupe, _ := ctx.Value(ContextDownloadDedupe).(*downloadDedupe)
+	if dedupe.copyTo(ctx, file, path) {
+		return nil
+	}
+	if err := getOrDefault[downloaderFunc](ctx, ContextFileDownloadType, fileDownloadAuto)(ctx, file, path); err != nil {
+		return err
+	}
+	dedupe.remember(file, path)
+	return nil
+}
+
+// GenericDownloadReader streams the file, so it could be processed without touching the disk.
+// Reader must be closed, for DownloadTypeLocalMove closing the reader also removes the file from the local server.
+// Reading fails with ErrorDownloadTooLarge or ErrorDownloadTruncated, if the file turns out to be too big or incomplete.
+func GenericDownloadReader(ctx context.Context, fileId string) (io.ReadCloser, *File, error) {
+	file, err := GetFile(ctx, fileId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := downloadCheckSize(ctx, file, file.FileSize); err != nil {
+		return nil, file, err
+	}
+
+	reader, err := getOrDefault[readerFunc](ctx, ContextFileReaderType, fileReaderAuto)(ctx, file)
+	if err != nil {
+		return nil, file, err
+	}
+	return newDownloadVerifyingReader(ctx, file, reader), file, nil
 }
 
 type downloaderFunc = func(ctx context.Context, file *File, path string) error
 
+type readerFunc = func(ctx context.Context, file *File) (io.ReadCloser, error)
+
 var (
+	_ downloaderFunc = fileDownloadAuto
 	_ downloaderFunc = fileDownloadClassic
 	_ downloaderFunc = fileDownloadLocalCopy
 	_ downloaderFunc = fileDownloadLocalMove
+
+	_ readerFunc = fileReaderAuto
+	_ readerFunc = fileReaderClassic
+	_ readerFunc = fileReaderLocalCopy
+	_ readerFunc = fileReaderLocalMove
 )
 
 func fileDownloadClassic(ctx context.Context, file *File, path string) error {
-	token, err := tryGetTokenFromContext(ctx)
+	reader, err := fileReaderClassic(ctx, file)
 	if err != nil {
 		return err
 	}
+	defer func(reader io.ReadCloser) { _ = reader.Close() }(reader)
 
-	output, err := os.Create(path)
+	return writeFileAtomicFrom(path, newDownloadVerifyingReader(ctx, file, reader))
+}
+
+func fileReaderClassic(ctx context.Context, file *File) (io.ReadCloser, error) {
+	token, err := tryGetTokenFromContext(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer func(output *os.File) { _ = output.Close() }(output)
 
 	url := fmt.Sprintf("%s/file/bot%s/%s", getOrDefault(ctx, ContextApiUrl, DefaultTelegramApiUrl), token, file.FilePath)
 	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if headers := getOrDefault(ctx, ContextExtraHeaders, map[string]string{}); headers != nil {
 		for name, value := range headers {
@@ -318,50 +433,57 @@ func fileDownloadClassic(ctx context.Context, file *File, path string) error {
 
 	resp, err := getOrDefault(ctx, ContextHttpClient, http.DefaultClient).Do(httpRequest)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer func(body io.ReadCloser) { _ = body.Close() }(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("telegram download file: bad status (%s)", resp.Status)
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("telegram download file: bad status (%s)", resp.Status)
 	}
 
-	if _, err = io.Copy(output, resp.Body); err != nil {
-		return err
-	}
+	return resp.Body, nil
+}
 
-	return nil
+func fileDownloadLocalCopy(ctx context.Context, file *File, path string) error {
+	return fileCopy(ctx, localServerFile(ctx, file), path)
 }
 
-func fileDownloadLocalCopy(ctx context.Context, file *File, path string) (err error) {
+// fileCopy copies the file at File.FilePath of this machine.
+func fileCopy(ctx context.Context, file *File, path string) error {
 	source, err := os.Open(file.FilePath)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = source.Close() }()
 
-	destination, err := os.Create(path)
+	return writeFileAtomicFrom(path, newDownloadVerifyingReader(ctx, file, source))
+}
+
+func fileReaderLocalCopy(ctx context.Context, file *File) (io.ReadCloser, error) {
+	return os.Open(localServerFile(ctx, file).FilePath)
+}
+
+func fileDownloadLocalMove(ctx context.Context, file *File, path string) (err error) {
+	file = localServerFile(ctx, file)
+	stat, err := os.Stat(file.FilePath)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		_ = destination.Close()
-		if err != nil {
-			_ = os.Remove(destination.Name())
-		}
-	}()
-
-	if _, err = io.Copy(destination, source); err != nil {
+	if err := downloadCheckSize(ctx, file, stat.Size()); err != nil {
 		return err
 	}
-	return nil
-}
+	if file.FileSize > 0 && stat.Size() != file.FileSize {
+		return &ErrorDownloadTruncated{FileId: file.FileId, Expected: file.FileSize, Actual: stat.Size()}
+	}
 
-func fileDownloadLocalMove(ctx context.Context, file *File, path string) (err error) {
+	_, pathErr := os.Stat(path)
+	created := errors.Is(pathErr, os.ErrNotExist)
 	defer func() {
 		if err != nil {
 			_ = os.Remove(file.FilePath)
-			_ = os.Remove(path)
+			if created {
+				_ = os.Remove(path)
+			}
 		}
 	}()
 
@@ -371,7 +493,7 @@ func fileDownloadLocalMove(ctx context.Context, file *File, path string) (err er
 		return nil
 
 	case strings.Contains(renameErr.Error(), "invalid cross-device link"):
-		if err := fileDownloadLocalCopy(ctx, file, path); err != nil {
+		if err := fileCopy(ctx, file, path); err != nil {
 			return err
 		}
 		return os.Remove(file.FilePath)
@@ -380,3 +502,133 @@ func fileDownloadLocalMove(ctx context.Context, file *File, path string) (err er
 		return renameErr
 	}
 }
+
+func fileReaderLocalMove(ctx context.Context, file *File) (io.ReadCloser, error) {
+	source, err := os.Open(localServerFile(ctx, file).FilePath)
+	if err != nil {
+		return nil, err
+	}
+	return &fileReaderRemoveOnClose{File: source}, nil
+}
+
+// fileReaderRemoveOnClose mimics DownloadTypeLocalMove for readers: the file is "moved" into the reader.
+type fileReaderRemoveOnClose struct {
+	*os.File
+}
+
+func (reader *fileReaderRemoveOnClose) Close() error {
+	return errors.Join(reader.File.Close(), os.Remove(reader.File.Name()))
+}
+
+// writeFileAtomicFrom writes to a temporary file next to the path, then renames it,
+// so the path contains either the complete file or nothing at all.
+func writeFileAtomicFrom(path string, reader io.Reader) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = io.Copy(tmp, reader); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// WithDownloadMaxSize limits the size of downloaded files, bigger files fail with ErrorDownloadTooLarge.
+func WithDownloadMaxSize(bytes int64) ExtraContext {
+	return func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, ContextDownloadMaxSize, bytes)
+	}
+}
+
+func downloadCheckSize(ctx context.Context, file *File, size int64) error {
+	maxSize := getOrDefault[int64](ctx, ContextDownloadMaxSize, 0)
+	if maxSize > 0 && size > maxSize {
+		return &ErrorDownloadTooLarge{FileId: file.FileId, Size: size, MaxSize: maxSize}
+	}
+	return nil
+}
+
+// downloadVerifyingReader checks the max size while reading and the expected File.FileSize on EOF.
+type downloadVerifyingReader struct {
+	io.ReadCloser
+	file    *File
+	maxSize int64
+	read    int64
+}
+
+func newDownloadVerifyingReader(ctx context.Context, file *File, reader io.ReadCloser) io.ReadCloser {
+	return &downloadVerifyingReader{
+		ReadCloser: reader,
+		file:       file,
+		maxSize:    getOrDefault[int64](ctx, ContextDownloadMaxSize, 0),
+		read:       0,
+	}
+}
+
+func (reader *downloadVerifyingReader) Read(p []byte) (int, error) {
+	n, err := reader.ReadCloser.Read(p)
+	reader.read += int64(n)
+	if reader.maxSize > 0 && reader.read > reader.maxSize {
+		return n, &ErrorDownloadTooLarge{FileId: reader.file.FileId, Size: reader.read, MaxSize: reader.maxSize}
+	}
+	if errors.Is(err, io.EOF) && reader.file.FileSize > 0 && reader.read != reader.file.FileSize {
+		return n, &ErrorDownloadTruncated{FileId: reader.file.FileId, Expected: reader.file.FileSize, Actual: reader.read}
+	}
+	return n, err
+}
+
+// downloadDedupe remembers where files were downloaded by their FileUniqueId,
+// the same file is copied locally instead of being downloaded again.
+type downloadDedupe struct {
+	mutex sync.Mutex
+	paths map[string]string
+}
+
+func (dedupe *downloadDedupe) copyTo(ctx context.Context, file *File, path string) bool {
+	if dedupe == nil || file.FileUniqueId == "" {
+		return false
+	}
+	dedupe.mutex.Lock()
+	source, ok := dedupe.paths[file.FileUniqueId]
+	dedupe.mutex.Unlock()
+	if !ok || source == path {
+		return false
+	}
+
+	stat, err := os.Stat(source)
+	if err != nil || file.FileSize > 0 && stat.Size() != file.FileSize {
+		dedupe.forget(file)
+		return false
+	}
+	return fileCopy(ctx, &File{
+		FileId:       file.FileId,
+		FileUniqueId: file.FileUniqueId,
+		FileSize:     file.FileSize,
+		FilePath:     source,
+	}, path) == nil
+}
+
+func (dedupe *downloadDedupe) remember(file *File, path string) {
+	if dedupe == nil || file.FileUniqueId == "" {
+		return
+	}
+	dedupe.mutex.Lock()
+	defer dedupe.mutex.Unlock()
+	dedupe.paths[file.FileUniqueId] = path
+}
+
+func (dedupe *downloadDedupe) forget(file *File) {
+	dedupe.mutex.Lock()
+	defer dedupe.mutex.Unlock()
+	delete(dedupe.paths, file.FileUniqueId)
+}
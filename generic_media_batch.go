@@ -0,0 +1,145 @@
+package tg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+)
+
+const defaultDownloadBatchParallelism = 4
+
+// DownloadItem is a single file of DownloadBatch, empty Path means a temporary file (alike GenericDownloadTemp).
+type DownloadItem struct {
+	FileId string
+	Path   string
+}
+
+// DownloadResult corresponds to DownloadItem of the same index, a failed temporary file is removed (and Path is empty).
+type DownloadResult struct {
+	FileId string
+	Path   string
+	File   *File
+	Error  error
+}
+
+type ConfigDownloadBatch struct {
+	// Parallelism limits concurrent downloads, 4 by default.
+	Parallelism int
+	// DirAndPattern are passed to os.CreateTemp for items without Path.
+	DirAndPattern []string
+}
+
+// DownloadBatch downloads files concurrently, getFile is called once per unique FileId (and goes through the Scheduler),
+// repeated file ids are downloaded once and copied locally. Works with every DownloadType.
+//
+// Example:
+//
+//	tg.HandleAlbum(func(ctx context.Context, updates []*tg.Update) error {
+//		items := []*tg.DownloadItem{}
+//		for _, upd := range updates {
+//			items = append(items, &tg.DownloadItem{FileId: upd.Message.MediaFileId()})
+//		}
+//		for _, result := range tg.DownloadBatch(ctx, items) {
+//			...
+//		}
+//	})
+func DownloadBatch(ctx context.Context, items []*DownloadItem, cfg ...*ConfigDownloadBatch) []*DownloadResult {
+	config := at(cfg, 0, &ConfigDownloadBatch{})
+	parallelism := config.Parallelism
+	if parallelism <= 0 {
+		parallelism = defaultDownloadBatchParallelism
+	}
+
+	results := make([]*DownloadResult, len(items))
+	groups := map[string][]int{}
+	order := []string{}
+	for i, item := range items {
+		results[i] = &DownloadResult{FileId: item.FileId, Path: item.Path}
+		if _, ok := groups[item.FileId]; !ok {
+			order = append(order, item.FileId)
+		}
+		groups[item.FileId] = append(groups[item.FileId], i)
+	}
+
+	semaphore := make(chan struct{}, parallelism)
+	wg := &sync.WaitGroup{}
+	wg.Add(len(order))
+	for _, fileId := range order {
+		go func(group []*DownloadResult) {
+			defer wg.Done()
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				for _, result := range group {
+					result.Error = ctx.Err()
+				}
+				return
+			}
+			downloadBatchGroup(ctx, group, config.DirAndPattern)
+		}(pick(results, groups[fileId]))
+	}
+	wg.Wait()
+
+	return results
+}
+
+// downloadBatchGroup downloads the file once into the first result's path, the rest are local copies.
+func downloadBatchGroup(ctx context.Context, group []*DownloadResult, dirAndPattern []string) {
+	file, err := GetFile(ctx, group[0].FileId)
+	if err != nil {
+		for _, result := range group {
+			result.Error = err
+		}
+		return
+	}
+
+	var source *File
+	for _, result := range group {
+		result.File = file
+		temp := result.Path == ""
+		if temp {
+			if result.Path, result.Error = downloadBatchTemp(dirAndPattern); result.Error != nil {
+				continue
+			}
+		}
+
+		if source == nil {
+			result.Error = downloadFile(ctx, file, result.Path)
+			if result.Error == nil {
+				source = &File{
+					FileId:       file.FileId,
+					FileUniqueId: file.FileUniqueId,
+					FileSize:     file.FileSize,
+					FilePath:     result.Path,
+				}
+			}
+		} else {
+			result.Error = fileCopy(ctx, source, result.Path)
+		}
+		if result.Error != nil && temp {
+			_ = os.Remove(result.Path)
+			result.Path = ""
+		}
+	}
+}
+
+func downloadBatchTemp(dirAndPattern []string) (string, error) {
+	tmp, err := os.CreateTemp(at(dirAndPattern, 0, ""), at(dirAndPattern, 1, ""))
+	if err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", errors.Join(err, os.Remove(tmp.Name()))
+	}
+	return tmp.Name(), nil
+}
+
+func pick[T any](list []T, indices []int) []T {
+	result := make([]T, len(indices))
+	for i, index := range indices {
+		result[i] = list[index]
+	}
+	return result
+}
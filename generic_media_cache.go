@@ -0,0 +1,268 @@
+package tg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FileCache remembers file_id-s of uploaded LocalFile-s, so the same file is uploaded only once
+// and is sent as a CloudFile afterward. Enable it with Bot.FileCache.
+//
+// Example:
+//
+//	storage, err := tg.NewFileCacheStorageJSON("file_cache.json")
+//	...
+//	tg.NewFromEnv().
+//		FileCache(tg.NewFileCache(storage)).
+//		Command("/banner", func(ctx context.Context, upd *tg.Update) error {
+//			_, err := tg.SendPhoto(ctx, upd.Message.Chat.Id, tg.FromDisk("assets/banner.png"))
+//			return err // the banner is uploaded on the first call only.
+//		}).
+//		Start()
+type FileCache struct {
+	storage      FileCacheStorage
+	hashContents bool
+}
+
+// NewFileCache keys files by path, size and modification time, in-memory storage is used by default.
+func NewFileCache(storage ...FileCacheStorage) *FileCache {
+	return &FileCache{
+		storage:      at(storage, 0, NewFileCacheStorageMemory()),
+		hashContents: false,
+	}
+}
+
+// NewFileCacheHashed keys files by sha256 of their contents, slower, but survives renames and touches.
+func NewFileCacheHashed(storage ...FileCacheStorage) *FileCache {
+	cache := NewFileCache(storage...)
+	cache.hashContents = true
+	return cache
+}
+
+// FileCacheStorage persists key->file_id pairs of FileCache.
+type FileCacheStorage interface {
+	Load(key string) (fileId string, ok bool)
+	Store(key string, fileId string) error
+	Delete(key string) error
+}
+
+var (
+	_ FileCacheStorage = (*fileCacheStorageMemory)(nil)
+	_ FileCacheStorage = (*fileCacheStorageJSON)(nil)
+)
+
+func NewFileCacheStorageMemory() FileCacheStorage {
+	return &fileCacheStorageMemory{}
+}
+
+type fileCacheStorageMemory struct {
+	cache sync.Map
+}
+
+func (storage *fileCacheStorageMemory) Load(key string) (string, bool) {
+	fileId, ok := storage.cache.Load(key)
+	if !ok {
+		return "", false
+	}
+	return fileId.(string), true
+}
+
+func (storage *fileCacheStorageMemory) Store(key string, fileId string) error {
+	storage.cache.Store(key, fileId)
+	return nil
+}
+
+func (storage *fileCacheStorageMemory) Delete(key string) error {
+	storage.cache.Delete(key)
+	return nil
+}
+
+// NewFileCacheStorageJSON keeps the whole cache in memory and rewrites the JSON file on every change.
+func NewFileCacheStorageJSON(path string) (FileCacheStorage, error) {
+	store, err := newJSONFileStore[string]("file cache", path)
+	if err != nil {
+		return nil, err
+	}
+	return &fileCacheStorageJSON{store: store}, nil
+}
+
+type fileCacheStorageJSON struct {
+	store *jsonFileStore[string]
+}
+
+func (storage *fileCacheStorageJSON) Load(key string) (string, bool) {
+	return storage.store.Load(key)
+}
+
+func (storage *fileCacheStorageJSON) Store(key string, fileId string) error {
+	return storage.store.Store(key, fileId)
+}
+
+func (storage *fileCacheStorageJSON) Delete(key string) error {
+	return storage.store.Delete(key)
+}
+
+// key identifies the file on disk, empty key means the file could not be cached.
+func (cache *FileCache) key(file *LocalFile) string {
+	stat, err := os.Stat(file.Path)
+	if err != nil || !stat.Mode().IsRegular() {
+		return ""
+	}
+	if !cache.hashContents {
+		path, err := filepath.Abs(file.Path)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("%s:%d:%d", path, stat.Size(), stat.ModTime().UnixNano())
+	}
+
+	input, err := os.Open(file.Path)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = input.Close() }()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, input); err != nil {
+		return ""
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// fileCacheEntry is a LocalFile found in a request, Index points to the message in a media group (-1 otherwise).
+type fileCacheEntry struct {
+	Key    string
+	Index  int
+	Cached bool
+}
+
+// fileCacheCacheableFields are request fields which file_id could be reused, i.e. thumbnails must always be uploaded.
+var fileCacheCacheableFields = []string{
+	"photo", "video", "audio", "document", "animation", "voice", "video_note", "sticker", "media",
+}
+
+// fileCacheSubstitute replaces cached LocalFile-s in the request with CloudFile-s.
+func fileCacheSubstitute[Request any](ctx context.Context, request *Request) []fileCacheEntry {
+	cache, ok := ctx.Value(ContextFileCache).(*FileCache)
+	if !ok || cache == nil {
+		return nil
+	}
+
+	entries := []fileCacheEntry{}
+	requestReplaceInputFiles(request, func(field string, index int, file InputFile) InputFile {
+		if !slices.Contains(fileCacheCacheableFields, field) {
+			return file
+		}
+		substitute, entry, ok := cache.substitute(file, index)
+		if !ok {
+			return file
+		}
+		entries = append(entries, entry)
+		return substitute
+	})
+	return entries
+}
+
+func (cache *FileCache) substitute(file InputFile, index int) (InputFile, fileCacheEntry, bool) {
+	local, ok := file.(*LocalFile)
+	if !ok || local == nil {
+		return nil, fileCacheEntry{}, false
+	}
+	key := cache.key(local)
+	if key == "" {
+		return nil, fileCacheEntry{}, false
+	}
+	if fileId, ok := cache.storage.Load(key); ok {
+		return FromCloud(fileId), fileCacheEntry{Key: key, Index: index, Cached: true}, true
+	}
+	return file, fileCacheEntry{Key: key, Index: index, Cached: false}, true
+}
+
+// fileCacheRemember stores file_id-s of freshly uploaded files,
+// or forgets cached file_id-s rejected by Telegram, so they are uploaded once again next time.
+func fileCacheRemember(ctx context.Context, entries []fileCacheEntry, result any, err error) {
+	cache, ok := ctx.Value(ContextFileCache).(*FileCache)
+	if !ok || cache == nil || len(entries) == 0 {
+		return
+	}
+
+	if err != nil {
+		index, ok := fileCacheRejected(err)
+		if !ok {
+			return
+		}
+		for _, entry := range entries {
+			if !entry.Cached || (index >= 0 && entry.Index != index) {
+				continue
+			}
+			if err := cache.storage.Delete(entry.Key); err != nil {
+				slog.Warn("tg.FileCache#delete", "key", entry.Key, "err", err)
+			}
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.Cached {
+			continue
+		}
+		var msg *Message
+		switch result := result.(type) {
+		case *Message:
+			msg = result
+		case []*Message:
+			if entry.Index >= 0 && entry.Index < len(result) {
+				msg = result[entry.Index]
+			}
+		}
+		fileId := msg.MediaFileId()
+		if fileId == "" {
+			continue
+		}
+		if err := cache.storage.Store(entry.Key, fileId); err != nil {
+			slog.Warn("tg.FileCache#store", "key", entry.Key, "err", err)
+		}
+	}
+}
+
+// fileCacheRejectedErrors are parts of Telegram errors about a file_id that can't be used anymore.
+var fileCacheRejectedErrors = []string{
+	"wrong file identifier",
+	"wrong remote file id",
+	"file reference",
+	"can't use file of type",
+}
+
+// fileCacheRejectedMessage is how Telegram points to a failed message of a media group, e.g.
+// "Bad Request: failed to send message #2 with the error message \"wrong file identifier/HTTP URL specified\"".
+var fileCacheRejectedMessage = regexp.MustCompile(`message #(\d+)`)
+
+// fileCacheRejected reports whether Telegram rejected a file_id, index is the media group index of the rejected file
+// (-1 if unknown, then any cached file of the request could be the one).
+func fileCacheRejected(err error) (index int, ok bool) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return -1, false
+	}
+	description := strings.ToLower(apiErr.Description)
+	if !slices.ContainsFunc(fileCacheRejectedErrors, func(part string) bool { return strings.Contains(description, part) }) {
+		return -1, false
+	}
+	if match := fileCacheRejectedMessage.FindStringSubmatch(description); match != nil {
+		if number, err := strconv.Atoi(match[1]); err == nil {
+			return number - 1, true
+		}
+	}
+	return -1, true
+}
@@ -0,0 +1,105 @@
+package tg
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Local Bot API server (https://github.com/tdlib/telegram-bot-api) started with --local
+// returns absolute file paths of its own filesystem and accepts file:// URIs instead of uploads.
+// In Docker the server's working directory (i.e. /var/lib/telegram-bot-api) is usually mounted elsewhere,
+// Config.LocalPathMapping translates server path prefixes to the paths of this process:
+//
+//	tg.New(&tg.Config{
+//		Token:            "...",
+//		ApiURL:           "http://telegram-bot-api:8081",
+//		LocalServer:      true,
+//		LocalPathMapping: map[string]string{"/var/lib/telegram-bot-api": "/mnt/telegram-bot-api"},
+//	})
+
+// WithLocalServer enables file:// uploads and the local server path mapping (server prefix -> local prefix).
+func WithLocalServer(mapping map[string]string) ExtraContext {
+	return func(ctx context.Context) context.Context {
+		ctx = context.WithValue(ctx, ContextLocalServer, true)
+		if len(mapping) == 0 {
+			return ctx
+		}
+		return context.WithValue(ctx, ContextLocalPathMapping, mapping)
+	}
+}
+
+// IsLocalServerFile reports whether the file was returned by a local Bot API server,
+// those are the only ones with absolute paths.
+func IsLocalServerFile(file *File) bool {
+	return file != nil && (filepath.IsAbs(file.FilePath) || strings.HasPrefix(file.FilePath, "/"))
+}
+
+// fileDownloadAuto is the default strategy: local move for local server files, classic download otherwise.
+func fileDownloadAuto(ctx context.Context, file *File, path string) error {
+	if IsLocalServerFile(file) {
+		return fileDownloadLocalMove(ctx, file, path)
+	}
+	return fileDownloadClassic(ctx, file, path)
+}
+
+func fileReaderAuto(ctx context.Context, file *File) (io.ReadCloser, error) {
+	if IsLocalServerFile(file) {
+		return fileReaderLocalMove(ctx, file)
+	}
+	return fileReaderClassic(ctx, file)
+}
+
+// localServerFile returns a copy of the file with File.FilePath mapped onto this process' filesystem.
+func localServerFile(ctx context.Context, file *File) *File {
+	mapping := getOrDefault(ctx, ContextLocalPathMapping, map[string]string{})
+	path, ok := mapPathPrefix(file.FilePath, mapping, false)
+	if !ok {
+		return file
+	}
+	mapped := *file
+	mapped.FilePath = path
+	return &mapped
+}
+
+// localServerSubstitute replaces LocalFile-s with file:// URIs, if the local server is able to see them.
+func localServerSubstitute[Request any](ctx context.Context, request *Request) {
+	if !getOrDefault(ctx, ContextLocalServer, false) {
+		return
+	}
+	mapping := getOrDefault(ctx, ContextLocalPathMapping, map[string]string{})
+	requestReplaceInputFiles(request, func(field string, index int, file InputFile) InputFile {
+		local, ok := file.(*LocalFile)
+		if !ok || local == nil {
+			return file
+		}
+		path, err := filepath.Abs(local.Path)
+		if err != nil {
+			return file
+		}
+		if len(mapping) != 0 {
+			if path, ok = mapPathPrefix(path, mapping, true); !ok {
+				return file
+			}
+		}
+		return FromCloud("file://" + filepath.ToSlash(path))
+	})
+}
+
+// mapPathPrefix replaces the longest matching prefix of mapping (server -> local), reversed maps local -> server.
+func mapPathPrefix(path string, mapping map[string]string, reversed bool) (string, bool) {
+	result, matched, found := "", "", false
+	for server, local := range mapping {
+		from, to := server, local
+		if reversed {
+			from, to = local, server
+		}
+		from = strings.TrimSuffix(from, "/")
+		if path != from && !strings.HasPrefix(path, from+"/") || found && len(from) < len(matched) {
+			continue
+		}
+		result, matched, found = strings.TrimSuffix(to, "/")+strings.TrimPrefix(path, from), from, true
+	}
+	return result, found
+}
@@ -0,0 +1,76 @@
+package tg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// jsonFileStore is a map mirrored to a JSON object on disk: reads are served from memory,
+// every change is written through with writeFileAtomicFrom.
+type jsonFileStore[V any] struct {
+	path  string
+	mutex sync.Mutex
+	items map[string]V
+}
+
+// newJSONFileStore reads the file if it exists, name prefixes errors about bad json (e.g. "state storage").
+func newJSONFileStore[V any](name string, path string) (*jsonFileStore[V], error) {
+	store := &jsonFileStore[V]{path: path, items: map[string]V{}}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return store, nil
+	case err != nil:
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.items); err != nil {
+		return nil, fmt.Errorf("%s: bad json at '%s' (%w)", name, path, err)
+	}
+	return store, nil
+}
+
+func (store *jsonFileStore[V]) Load(key string) (V, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	value, ok := store.items[key]
+	return value, ok
+}
+
+func (store *jsonFileStore[V]) Store(key string, value V) error {
+	return store.Update(func(items map[string]V) error {
+		items[key] = value
+		return nil
+	})
+}
+
+func (store *jsonFileStore[V]) Delete(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if _, ok := store.items[key]; !ok {
+		return nil
+	}
+	delete(store.items, key)
+	return store.flush()
+}
+
+// Update changes items under the lock, the file is written unless update fails.
+func (store *jsonFileStore[V]) Update(update func(items map[string]V) error) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := update(store.items); err != nil {
+		return err
+	}
+	return store.flush()
+}
+
+func (store *jsonFileStore[V]) flush() error {
+	data, err := json.Marshal(store.items)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomicFrom(store.path, bytes.NewReader(data))
+}
@@ -74,6 +74,7 @@ func (fac *Factory2) BuildTypesFromSchema(schema map[string]*Type) (string, stri
 		"package tg",
 		`import (
 "context"
+"io"
 )`,
 	}
 
@@ -276,6 +277,10 @@ func (fac *Factory2) buildStruct(typ *Type) string {
 					"return GenericDownloadTemp(ctx, impl.%s, dirAndPattern...)\n"+
 					"}", fac.Structs[typ.Name].Name, fieldName,
 				),
+				fmt.Sprintf("func (impl *%s) DownloadReader(ctx context.Context) (io.ReadCloser, *File, error) {\n"+
+					"return GenericDownloadReader(ctx, impl.%s)\n"+
+					"}", fac.Structs[typ.Name].Name, fieldName,
+				),
 			)
 		}
 		if strings.HasPrefix(typ.Name, "InputMedia") {
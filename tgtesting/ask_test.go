@@ -0,0 +1,119 @@
+package tgtesting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/kittenbark/tg"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAsk(t *testing.T) {
+	t.Parallel()
+
+	message := func(id int64, text string) *tg.Update {
+		cmd := command(text)
+		return &tg.Update{UpdateId: id, Message: &tg.Message{
+			MessageId: id,
+			Chat:      &tg.Chat{Id: 1},
+			From:      &tg.User{Id: 1},
+			Text:      cmd.String(),
+			Entities:  cmd.Entities(),
+		}}
+	}
+	photo := &tg.Update{UpdateId: 3, Message: &tg.Message{
+		MessageId: 3,
+		Chat:      &tg.Chat{Id: 1},
+		From:      &tg.User{Id: 1},
+		Photo:     []*tg.PhotoSize{{FileId: "photo"}},
+	}}
+	batches := [][]*tg.Update{
+		{},
+		{photo, message(2, "hello")},
+		{message(4, "/cancelable")},
+		{message(5, "/cancel")},
+		{message(6, "/slow")},
+	}
+
+	mutex, replies := sync.Mutex{}, []string{}
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/getUpdates", Result: func(req *http.Request) (int, *Response) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				if len(batches) == 0 {
+					return StubResultOK(http.StatusOK, []*tg.Update{})(req)
+				}
+				batch := batches[0]
+				batches = batches[1:]
+				return StubResultOK(http.StatusOK, batch)(req)
+			}},
+			{Url: "/sendMessage", Result: func(req *http.Request) (int, *Response) {
+				body := map[string]any{}
+				_ = json.NewDecoder(req.Body).Decode(&body)
+				mutex.Lock()
+				defer mutex.Unlock()
+				replies = append(replies, body["text"].(string))
+				return StubResultOK(http.StatusOK, &tg.Message{MessageId: 1, Chat: &tg.Chat{Id: 1}})(req)
+			}},
+		},
+	})
+
+	errs := make(chan error, 3)
+	ask := func(question string, timeout time.Duration, opt *tg.OptAsk) tg.HandlerFunc {
+		return func(ctx context.Context, upd *tg.Update) error {
+			answer, err := tg.Ask(ctx, upd, question, timeout, opt)
+			errs <- err
+			if err != nil {
+				return nil
+			}
+			return tg.CommonTextReply("got "+answer.Message.Photo[0].FileId)(ctx, answer)
+		}
+	}
+	bot := tg.New(&tg.Config{
+		Token:  ctx.Value(tg.ContextToken).(string),
+		ApiURL: ctx.Value(tg.ContextApiUrl).(string),
+	})
+	time.AfterFunc(time.Second, bot.Stop)
+	seen := &atomic.Int64{}
+	bot.
+		Use(func(next tg.HandlerFunc) tg.HandlerFunc {
+			return func(ctx context.Context, upd *tg.Update) error {
+				seen.Add(1)
+				return next(ctx, upd)
+			}
+		}).
+		Command("/avatar", ask("send me a photo", time.Second, &tg.OptAsk{Filter: tg.OnPhoto})).
+		Command("/cancelable", ask("", time.Second, &tg.OptAsk{CancelCommands: []string{"/cancel"}})).
+		Command("/slow", ask("", time.Millisecond*10, &tg.OptAsk{TimeoutReply: "too slow"})).
+		Handle(func(ctx context.Context, upd *tg.Update) error {
+			return tg.CommonTextReply("pipeline: "+upd.Message.Text)(ctx, upd)
+		}).
+		Start(message(1, "/avatar"))
+
+	require.NoError(t, <-errs)
+	var canceled *tg.ErrorAskCanceled
+	require.True(t, errors.As(<-errs, &canceled))
+	require.Equal(t, "/cancel", canceled.Command)
+	var timeout *tg.ErrorAskTimeout
+	require.True(t, errors.As(<-errs, &timeout))
+	require.Equal(t, int64(6), seen.Load()) // Answers go through middlewares too.
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	slices.Sort(replies)
+	require.Equal(t, []string{"got photo", "pipeline: hello", "send me a photo", "too slow"}, replies)
+
+	syncBot := tg.New(&tg.Config{
+		Token:        ctx.Value(tg.ContextToken).(string),
+		ApiURL:       ctx.Value(tg.ContextApiUrl).(string),
+		SyncHandling: true,
+	})
+	_, err := tg.Ask(syncBot.Context(), message(7, "/avatar"), "", time.Second)
+	require.Error(t, err)
+}
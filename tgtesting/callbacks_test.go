@@ -0,0 +1,632 @@
+package tgtesting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/kittenbark/tg"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyboardCallbackData(t *testing.T) {
+	t.Parallel()
+
+	pressed := ""
+	handler := func(ctx context.Context, upd *tg.Update) error {
+		pressed = upd.CallbackQuery.Data
+		return nil
+	}
+	long := strings.Repeat("Очень длинные данные ", 3)
+	layout := [][]tg.ButtonI{
+		{&tg.CallbackButton{Text: "Подтвердить оформление заказа сейчас", Handler: handler}},
+		{&tg.CallbackButton{Text: "long", Data: long, Handler: handler}},
+		{&tg.CallbackButton{Text: "payload", Data: `{"item":42}`, Handler: handler}},
+	}
+
+	_, err := (&tg.Keyboard{Layout: layout}).TryBuild()
+	var tooLong *tg.ErrorCallbackDataTooLong
+	require.True(t, errors.As(err, &tooLong))
+	require.Equal(t, len(long), tooLong.Size)
+
+	keyboard := &tg.Keyboard{Layout: layout, Storage: tg.NewCallbackDataStorageMemory()}
+	markup, err := keyboard.TryBuild()
+	require.NoError(t, err)
+	filter, handle := keyboard.Branch()
+	for i, expected := range []string{"1", long, `{"item":42}`} {
+		button := markup.InlineKeyboard[i][0]
+		require.LessOrEqualInt(t, tg.CallbackDataLimit, int64(len(button.CallbackData)))
+
+		upd := &tg.Update{CallbackQuery: &tg.CallbackQuery{Id: "1", Data: button.CallbackData}}
+		require.True(t, filter(context.Background(), upd))
+		require.NoError(t, handle(context.Background(), upd))
+		require.Equal(t, expected, pressed)
+	}
+
+	static := &tg.Keyboard{Layout: [][]tg.ButtonI{{&tg.Button{Text: "link", CallbackData: "raw"}, &tg.CallbackButton{Text: "ok", Handler: handler}}}}
+	expected := static.Build()
+	for range 10 {
+		require.Equal(t, expected, static.Build())
+	}
+
+	other := &tg.Keyboard{Layout: [][]tg.ButtonI{{&tg.CallbackButton{Text: "other", Handler: handler}}}}
+	otherMarkup := other.Build()
+	require.False(t, filter(context.Background(), &tg.Update{CallbackQuery: &tg.CallbackQuery{Data: otherMarkup.InlineKeyboard[0][0].CallbackData}}))
+	require.False(t, filter(context.Background(), &tg.Update{CallbackQuery: &tg.CallbackQuery{Data: `{"K":"1","B":1,"D":"x"}`}}))
+}
+
+func TestKeyboardRegistry(t *testing.T) {
+	t.Parallel()
+
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{{Url: "/getUpdates", Result: StubResultOK(http.StatusOK, []*tg.Update{})}},
+	})
+
+	events := []string{}
+	keyboard := func(text string, ttl time.Duration) *tg.Keyboard {
+		return &tg.Keyboard{
+			Layout: [][]tg.ButtonI{{&tg.CallbackButton{Text: text, Data: text, Handler: func(ctx context.Context, upd *tg.Update) error {
+				events = append(events, "pressed "+upd.CallbackQuery.Data)
+				return nil
+			}}}},
+			TTL: ttl,
+		}
+	}
+	press := func(keyboard *tg.Keyboard) *tg.Update {
+		return &tg.Update{CallbackQuery: &tg.CallbackQuery{
+			Id:   "1",
+			From: &tg.User{Id: 1},
+			Data: keyboard.Build().InlineKeyboard[0][0].CallbackData,
+		}}
+	}
+	message := func(text string) *tg.Update {
+		cmd := command(text)
+		return &tg.Update{Message: &tg.Message{Chat: &tg.Chat{Id: 1}, From: &tg.User{Id: 1}, Text: cmd.String(), Entities: cmd.Entities()}}
+	}
+
+	bot := tg.New(&tg.Config{
+		Token:        ctx.Value(tg.ContextToken).(string),
+		ApiURL:       ctx.Value(tg.ContextApiUrl).(string),
+		SyncHandling: true,
+	})
+	temp := keyboard("temp", time.Millisecond*20)
+	remove := func(item string) *tg.Keyboard {
+		return &tg.Keyboard{
+			Layout: [][]tg.ButtonI{{&tg.CallbackButton{Text: "Delete", Data: "delete", Handler: func(ctx context.Context, upd *tg.Update) error {
+				events = append(events, "deleted "+item)
+				return nil
+			}}}},
+			TTL: time.Minute,
+		}
+	}
+	removeA, removeB := remove("A"), remove("B")
+	time.AfterFunc(time.Millisecond*200, bot.Stop)
+	updates := []*tg.Update{
+		message("/menu"), message("/menu"), message("/menu"),
+		press(keyboard("menu", 0)),
+		message("/forget"),
+		press(keyboard("menu", 0)),
+		message("/temp"),
+		press(temp),
+		message("/sleep"),
+		press(temp),
+		message("/items"),
+		press(removeA),
+	}
+	slices.Reverse(updates)
+	bot.
+		Command("/menu", func(ctx context.Context, upd *tg.Update) error {
+			require.True(t, keyboard("menu", 0).BuildRegister(ctx) != nil)
+			return nil
+		}).
+		Command("/forget", func(ctx context.Context, upd *tg.Update) error {
+			ctx.Value(tg.ContextBotInstance).(*tg.Bot).UnregisterKeyboard(keyboard("menu", 0))
+			return nil
+		}).
+		Command("/temp", func(ctx context.Context, upd *tg.Update) error {
+			temp.BuildRegister(ctx)
+			return nil
+		}).
+		Command("/items", func(ctx context.Context, upd *tg.Update) error {
+			removeA.BuildRegister(ctx)
+			removeB.BuildRegister(ctx)
+			return nil
+		}).
+		Command("/sleep", func(ctx context.Context, upd *tg.Update) error {
+			time.Sleep(time.Millisecond * 30)
+			return nil
+		}).
+		Handle(func(ctx context.Context, upd *tg.Update) error {
+			events = append(events, "unhandled")
+			return nil
+		}).
+		Start(updates...)
+
+	require.Equal(t, []string{"pressed menu", "unhandled", "pressed temp", "unhandled", "deleted A"}, events)
+}
+
+func TestWidget(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		ReplyMarkup *tg.InlineKeyboardMarkup `json:"reply_markup"`
+	}
+	mutex, markups := sync.Mutex{}, []*tg.InlineKeyboardMarkup{}
+	record := func(req *http.Request) {
+		body := &request{}
+		_ = json.NewDecoder(req.Body).Decode(body)
+		mutex.Lock()
+		defer mutex.Unlock()
+		markups = append(markups, body.ReplyMarkup)
+	}
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/getUpdates", Result: StubResultOK(http.StatusOK, []*tg.Update{})},
+			{Url: "/sendMessage", Result: func(req *http.Request) (int, *Response) {
+				record(req)
+				return StubResultOK(http.StatusOK, &tg.Message{MessageId: 10, Chat: &tg.Chat{Id: 1}})(req)
+			}},
+			{Url: "/editMessageReplyMarkup", Result: func(req *http.Request) (int, *Response) {
+				record(req)
+				return StubResultOK(http.StatusOK, &tg.Message{MessageId: 10, Chat: &tg.Chat{Id: 1}})(req)
+			}},
+		},
+	})
+
+	type Order struct {
+		Size   string   `json:"size"`
+		Extras []string `json:"extras"`
+		Count  int      `json:"count"`
+		Gift   bool     `json:"gift"`
+	}
+	storage := tg.NewSessionStorageMemory()
+	widget := &tg.Widget[Order]{
+		Id: "order",
+		Render: func(ctx context.Context, order *Order) [][]*tg.WidgetButton {
+			return [][]*tg.WidgetButton{
+				tg.Radio(&order.Size, []string{"S", "M", "L"}, nil),
+				tg.MultiSelect(&order.Extras, []string{"milk", "syrup"}, strings.ToUpper),
+				tg.Counter(&order.Count, 1, 2),
+				{tg.Toggle("gift", &order.Gift)},
+			}
+		},
+		Storage: storage,
+	}
+	_, err := widget.Send(ctx, 1, "your order", &Order{Size: "M", Count: 1})
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"S", "• M •", "L"}, {"⬜ MILK", "⬜ SYRUP"}, {"−", "1", "+"}, {"⬜ gift"}}, markupTexts(markups[0]))
+
+	buttons := slices.Concat(markups[0].InlineKeyboard...)
+	press := func(button int) *tg.Update {
+		return &tg.Update{CallbackQuery: &tg.CallbackQuery{
+			Id:      "1",
+			From:    &tg.User{Id: 1},
+			Message: &tg.Message{MessageId: 10, Chat: &tg.Chat{Id: 1}},
+			Data:    buttons[button].CallbackData,
+		}}
+	}
+	bot := tg.New(&tg.Config{
+		Token:        ctx.Value(tg.ContextToken).(string),
+		ApiURL:       ctx.Value(tg.ContextApiUrl).(string),
+		SyncHandling: true,
+	})
+	time.AfterFunc(time.Millisecond*200, bot.Stop)
+	updates := []*tg.Update{press(2), press(2), press(4), press(7), press(7), press(8), press(5)}
+	slices.Reverse(updates)
+	bot.Branch(widget.Branch()).Start(updates...)
+
+	mutex.Lock()
+	require.Equal(t, 6, len(markups)) // The second press of "L" and the third "+" change nothing.
+	require.Equal(t, [][]string{{"S", "M", "• L •"}, {"⬜ MILK", "✅ SYRUP"}, {"−", "2", "+"}, {"✅ gift"}}, markupTexts(markups[4]))
+	require.Equal(t, [][]string{{"S", "M", "• L •"}, {"⬜ MILK", "✅ SYRUP"}, {"−", "1", "+"}, {"✅ gift"}}, markupTexts(markups[5]))
+	mutex.Unlock()
+
+	data, version, err := storage.Load("widget:order:1:10")
+	require.NoError(t, err)
+	require.Equal(t, int64(6), version)
+	require.Equal(t, `{"size":"L","extras":["syrup"],"count":1,"gift":true}`, string(data))
+
+	require.NoError(t, widget.Forget(1, 10))
+	data, _, err = storage.Load("widget:order:1:10")
+	require.NoError(t, err)
+	require.True(t, data == nil)
+
+	short := &tg.Widget[Order]{Id: "short", Render: widget.Render, Storage: storage, TTL: time.Millisecond}
+	_, err = short.Send(ctx, 1, "your order", &Order{Size: "M", Count: 1})
+	require.NoError(t, err)
+	mutex.Lock()
+	pressShort := &tg.Update{CallbackQuery: &tg.CallbackQuery{
+		Id:      "1",
+		From:    &tg.User{Id: 1},
+		Message: &tg.Message{MessageId: 10, Chat: &tg.Chat{Id: 1}},
+		Data:    markups[len(markups)-1].InlineKeyboard[0][0].CallbackData,
+	}}
+	mutex.Unlock()
+	time.Sleep(time.Millisecond * 5)
+	require.Error(t, short.HandlerFunc()(ctx, pressShort))
+	data, _, err = storage.Load("widget:short:1:10")
+	require.NoError(t, err)
+	require.True(t, data == nil)
+}
+
+func markupTexts(markup *tg.InlineKeyboardMarkup) [][]string {
+	result := [][]string{}
+	for _, row := range markup.InlineKeyboard {
+		texts := []string{}
+		for _, button := range row {
+			texts = append(texts, button.Text)
+		}
+		result = append(result, texts)
+	}
+	return result
+}
+
+func TestPaginator(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		ReplyMarkup *tg.InlineKeyboardMarkup `json:"reply_markup"`
+		Results     []map[string]any         `json:"results"`
+		NextOffset  string                   `json:"next_offset"`
+	}
+	mutex, requests := sync.Mutex{}, []*request{}
+	record := func(req *http.Request) {
+		body := &request{}
+		_ = json.NewDecoder(req.Body).Decode(body)
+		mutex.Lock()
+		defer mutex.Unlock()
+		requests = append(requests, body)
+	}
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/getUpdates", Result: StubResultOK(http.StatusOK, []*tg.Update{})},
+			{Url: "/editMessageReplyMarkup", Result: func(req *http.Request) (int, *Response) {
+				record(req)
+				return StubResultOK(http.StatusOK, &tg.Message{MessageId: 10, Chat: &tg.Chat{Id: 1}})(req)
+			}},
+			{Url: "/answerInlineQuery", Result: func(req *http.Request) (int, *Response) {
+				record(req)
+				return StubResultOK(http.StatusOK, true)(req)
+			}},
+		},
+	})
+
+	names := []string{}
+	for i := range 23 {
+		names = append(names, "file"+strconv.Itoa(i))
+	}
+	picked := ""
+	files := &tg.Paginator[string]{
+		Id:       "files",
+		PageSize: 5,
+		Columns:  2,
+		Source: func(ctx context.Context, offset int, limit int) ([]string, int, error) {
+			return names[min(offset, len(names)):min(offset+limit, len(names))], len(names), nil
+		},
+		Button: func(name string) *tg.CallbackButton {
+			return &tg.CallbackButton{Text: name, Data: name, Handler: func(ctx context.Context, upd *tg.Update) error {
+				picked = upd.CallbackQuery.Data
+				return nil
+			}}
+		},
+	}
+
+	config := &tg.Config{
+		Token:        ctx.Value(tg.ContextToken).(string),
+		ApiURL:       ctx.Value(tg.ContextApiUrl).(string),
+		SyncHandling: true,
+	}
+	// Pages are sent by the previous process, the bot handles them after a restart.
+	previous := tg.New(config)
+	first, err := files.Markup(previous.Context(), 0)
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"file0", "file1"}, {"file2", "file3"}, {"file4"}, {"1/5", "»"}}, markupTexts(first))
+	last, err := files.Markup(previous.Context(), 4)
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"file20", "file21"}, {"file22"}, {"«", "5/5"}}, markupTexts(last))
+	// All pages are served by the same keyboard, its id is the first 4 bytes (5 base64 chars) of the callback data.
+	require.Equal(t, first.InlineKeyboard[0][0].CallbackData[:5], last.InlineKeyboard[2][1].CallbackData[:5])
+
+	press := func(button *tg.InlineKeyboardButton) *tg.Update {
+		return &tg.Update{CallbackQuery: &tg.CallbackQuery{
+			Id:      "1",
+			From:    &tg.User{Id: 1},
+			Message: &tg.Message{MessageId: 10, Chat: &tg.Chat{Id: 1}},
+			Data:    button.CallbackData,
+		}}
+	}
+	inline := func(offset string) *tg.Update {
+		return &tg.Update{InlineQuery: &tg.InlineQuery{Id: "1", From: &tg.User{Id: 1}, Offset: offset}}
+	}
+	updates := []*tg.Update{
+		press(first.InlineKeyboard[3][1]),
+		press(first.InlineKeyboard[3][0]),
+		press(last.InlineKeyboard[1][0]),
+		inline(""),
+		inline("20"),
+	}
+	slices.Reverse(updates)
+	bot := tg.New(config)
+	time.AfterFunc(time.Millisecond*200, bot.Stop)
+	bot.
+		Branch(files.Branch()).
+		Branch(tg.OnInlineQuery, func(ctx context.Context, upd *tg.Update) error {
+			return files.InlineResults(ctx, upd, func(name string) tg.InlineQueryResult {
+				return &tg.InlineQueryResultArticle{
+					Type:                "article",
+					Id:                  name,
+					Title:               name,
+					InputMessageContent: &tg.InputTextMessageContent{MessageText: name},
+				}
+			})
+		}).
+		Start(updates...)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Equal(t, 3, len(requests))
+	require.Equal(t, [][]string{{"file5", "file6"}, {"file7", "file8"}, {"file9"}, {"«", "2/5", "»"}}, markupTexts(requests[0].ReplyMarkup))
+	require.Equal(t, "file22", picked)
+	require.Equal(t, 5, len(requests[1].Results))
+	require.Equal(t, "5", requests[1].NextOffset)
+	require.Equal(t, 3, len(requests[2].Results))
+	require.Equal(t, "", requests[2].NextOffset)
+}
+
+func TestReplyKeyboard(t *testing.T) {
+	t.Parallel()
+
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{{Url: "/getUpdates", Result: StubResultOK(http.StatusOK, []*tg.Update{})}},
+	})
+
+	events := []string{}
+	keyboard := &tg.ReplyKeyboard{
+		Layout: [][]tg.ReplyButtonI{
+			{&tg.ReplyButton{Text: "Menu", Handler: func(ctx context.Context, upd *tg.Update) error {
+				events = append(events, "menu")
+				return nil
+			}}},
+			{
+				&tg.ContactButton{Text: "Phone", Handler: func(ctx context.Context, upd *tg.Update, contact *tg.Contact) error {
+					events = append(events, "contact "+contact.PhoneNumber)
+					return nil
+				}},
+				&tg.LocationButton{Text: "Location", Handler: func(ctx context.Context, upd *tg.Update, location *tg.Location) error {
+					events = append(events, "location "+strconv.FormatFloat(location.Latitude, 'f', 1, 64))
+					return nil
+				}},
+			},
+			{
+				&tg.UsersButton{
+					Text:    "Friends",
+					Request: &tg.KeyboardButtonRequestUsers{MaxQuantity: 3},
+					Handler: func(ctx context.Context, upd *tg.Update, shared *tg.UsersShared) error {
+						events = append(events, "users "+strconv.Itoa(len(shared.Users)))
+						return nil
+					},
+				},
+				&tg.ChatButton{Text: "Group", Handler: func(ctx context.Context, upd *tg.Update, shared *tg.ChatShared) error {
+					events = append(events, "chat "+strconv.FormatInt(shared.ChatId, 10))
+					return nil
+				}},
+			},
+		},
+		Resize: true,
+	}
+	markup := keyboard.Build()
+	require.True(t, markup.ResizeKeyboard)
+	require.True(t, markup.Keyboard[1][0].RequestContact)
+	require.True(t, markup.Keyboard[1][1].RequestLocation)
+	usersRequest, chatRequest := markup.Keyboard[2][0].RequestUsers, markup.Keyboard[2][1].RequestChat
+	require.Equal(t, int64(3), usersRequest.MaxQuantity)
+	require.True(t, usersRequest.RequestId > 0 && chatRequest.RequestId > 0 && usersRequest.RequestId != chatRequest.RequestId)
+
+	message := func(message *tg.Message) *tg.Update {
+		message.Chat, message.From = &tg.Chat{Id: 1}, &tg.User{Id: 1}
+		return &tg.Update{Message: message}
+	}
+	bot := tg.New(&tg.Config{
+		Token:        ctx.Value(tg.ContextToken).(string),
+		ApiURL:       ctx.Value(tg.ContextApiUrl).(string),
+		SyncHandling: true,
+	})
+	time.AfterFunc(time.Millisecond*200, bot.Stop)
+	updates := []*tg.Update{
+		message(&tg.Message{Text: "Menu"}),
+		message(&tg.Message{Text: "menu"}),
+		message(&tg.Message{Contact: &tg.Contact{PhoneNumber: "+100", UserId: 1}}),
+		message(&tg.Message{Contact: &tg.Contact{PhoneNumber: "+200", UserId: 2}}),
+		message(&tg.Message{Location: &tg.Location{Latitude: 55.7, Longitude: 37.6}}),
+		message(&tg.Message{UsersShared: &tg.UsersShared{RequestId: usersRequest.RequestId, Users: []*tg.SharedUser{{UserId: 2}, {UserId: 3}}}}),
+		message(&tg.Message{ChatShared: &tg.ChatShared{RequestId: chatRequest.RequestId, ChatId: -100}}),
+		message(&tg.Message{ChatShared: &tg.ChatShared{RequestId: chatRequest.RequestId + 1, ChatId: -200}}),
+	}
+	slices.Reverse(updates)
+	bot.
+		Branch(keyboard.Branch()).
+		Handle(func(ctx context.Context, upd *tg.Update) error {
+			events = append(events, "unhandled")
+			return nil
+		}).
+		Start(updates...)
+
+	require.Equal(t, []string{
+		"menu", "unhandled", "contact +100", "unhandled", "location 55.7", "users 2", "chat -100", "unhandled",
+	}, events)
+}
+
+func TestCallbackSigner(t *testing.T) {
+	t.Parallel()
+
+	mutex, alerts, errs := sync.Mutex{}, []string{}, []error{}
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/getUpdates", Result: StubResultOK(http.StatusOK, []*tg.Update{})},
+			{Url: "/answerCallbackQuery", Result: func(req *http.Request) (int, *Response) {
+				body := map[string]any{}
+				_ = json.NewDecoder(req.Body).Decode(&body)
+				mutex.Lock()
+				defer mutex.Unlock()
+				require.Equal(t, true, body["show_alert"])
+				alerts = append(alerts, body["text"].(string))
+				return StubResultOK(http.StatusOK, true)(req)
+			}},
+		},
+	})
+
+	type Vote struct {
+		Option int `json:"o"`
+	}
+	secret := []byte("kitten")
+	bot := tg.New(&tg.Config{
+		Token:        ctx.Value(tg.ContextToken).(string),
+		ApiURL:       ctx.Value(tg.ContextApiUrl).(string),
+		SyncHandling: true,
+		OnError: func(ctx context.Context, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			errs = append(errs, err)
+		},
+	}).CallbackSigner(tg.NewCallbackSigner(secret, time.Hour).Alert("expired!"))
+
+	valid, err := tg.SignCallbackData(bot.Context(), &Vote{Option: 1})
+	require.NoError(t, err)
+	require.LessOrEqualInt(t, tg.CallbackDataLimit, int64(len(valid)))
+	expired, err := tg.NewCallbackSigner(secret, time.Nanosecond).Sign(`{"o":3}`)
+	require.NoError(t, err)
+	forged, err := tg.NewCallbackSigner([]byte("dog"), time.Hour).Sign(`{"o":4}`)
+	require.NoError(t, err)
+	_, err = tg.SignCallbackData(bot.Context(), strings.Repeat("x", 50))
+	require.Error(t, err)
+
+	press := func(data string) *tg.Update {
+		return &tg.Update{CallbackQuery: &tg.CallbackQuery{Id: "1", From: &tg.User{Id: 1}, Data: data}}
+	}
+	events := []string{}
+	keyboard := &tg.Keyboard{Layout: [][]tg.ButtonI{{&tg.CallbackButton{Text: "press", Handler: func(ctx context.Context, upd *tg.Update) error {
+		events = append(events, "pressed")
+		return nil
+	}}}}}
+	time.AfterFunc(time.Millisecond*200, bot.Stop)
+	updates := []*tg.Update{
+		press(valid),
+		press(keyboard.Build().InlineKeyboard[0][0].CallbackData),
+		press(keyboard.Build().InlineKeyboard[0][0].CallbackData[:9] + `{"o":7}`),
+		press(`{"o":2}`),
+		press(strings.Replace(valid, `"o":1`, `"o":9`, 1)),
+		press(expired),
+		press(forged),
+	}
+	slices.Reverse(updates)
+	bot.
+		RegisterKeyboard(keyboard).
+		Branch(tg.OnCallbackWithData[Vote](), func(ctx context.Context, upd *tg.Update) error {
+			vote, err := tg.CallbackData[Vote](upd)
+			if err != nil {
+				return err
+			}
+			events = append(events, "vote "+strconv.Itoa(vote.Option))
+			return nil
+		}).
+		Handle(func(ctx context.Context, upd *tg.Update) error {
+			events = append(events, "unhandled")
+			return nil
+		}).
+		Start(updates...)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Equal(t, []string{"vote 1", "pressed"}, events)
+	require.Equal(t, []string{"expired!", "expired!", "expired!", "expired!"}, alerts)
+	require.Equal(t, 5, len(errs))
+	var invalid *tg.ErrorCallbackDataInvalid
+	require.True(t, errors.As(errs[0], &invalid))
+	require.Equal(t, "data of another button", invalid.Reason)
+	require.True(t, errors.As(errs[1], &invalid))
+	require.Equal(t, "not signed", invalid.Reason)
+	require.True(t, errors.As(errs[3], &invalid))
+	require.True(t, invalid.Expired)
+	require.True(t, errors.As(errs[4], &invalid))
+	require.False(t, invalid.Expired)
+}
+
+func TestAutoAnswerCallbacks(t *testing.T) {
+	t.Parallel()
+
+	mutex, answers := sync.Mutex{}, []string{}
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/getUpdates", Result: StubResultOK(http.StatusOK, []*tg.Update{})},
+			{Url: "/answerCallbackQuery", Result: func(req *http.Request) (int, *Response) {
+				body := map[string]any{}
+				_ = json.NewDecoder(req.Body).Decode(&body)
+				mutex.Lock()
+				defer mutex.Unlock()
+				answer := body["callback_query_id"].(string) + ":"
+				if text, ok := body["text"].(string); ok {
+					answer += text
+				}
+				if body["show_alert"] == true {
+					answer += " (alert)"
+				}
+				answers = append(answers, answer)
+				return StubResultOK(http.StatusOK, true)(req)
+			}},
+		},
+	})
+
+	bot := tg.New(&tg.Config{
+		Token:        ctx.Value(tg.ContextToken).(string),
+		ApiURL:       ctx.Value(tg.ContextApiUrl).(string),
+		SyncHandling: true,
+	})
+	press := func(id string) *tg.Update {
+		return &tg.Update{CallbackQuery: &tg.CallbackQuery{Id: id, From: &tg.User{Id: 1}, Data: id}}
+	}
+	keyboard := &tg.Keyboard{Layout: [][]tg.ButtonI{{
+		&tg.CallbackButton{Text: "ok", Handler: func(ctx context.Context, upd *tg.Update) error { return nil }},
+		&tg.CallbackButton{Text: "fail", Handler: func(ctx context.Context, upd *tg.Update) error { return errors.New("fail") }},
+	}}}
+	markup := keyboard.Build()
+	pressButton := func(id string, button int) *tg.Update {
+		upd := press(id)
+		upd.CallbackQuery.Data = markup.InlineKeyboard[0][button].CallbackData
+		return upd
+	}
+
+	time.AfterFunc(time.Millisecond*200, bot.Stop)
+	updates := []*tg.Update{
+		press("answered"),
+		press("forgotten"),
+		press("failed"),
+		pressButton("button", 0),
+		pressButton("button_failed", 1),
+		{Message: &tg.Message{Chat: &tg.Chat{Id: 1}, From: &tg.User{Id: 1}, Text: "hi"}},
+	}
+	slices.Reverse(updates)
+	bot.
+		AutoAnswerCallbacks("oops").
+		RegisterKeyboard(keyboard).
+		Branch(tg.OnCallback, func(ctx context.Context, upd *tg.Update) error {
+			switch upd.CallbackQuery.Data {
+			case "answered":
+				_, err := tg.AnswerCallbackQuery(ctx, upd.CallbackQuery.Id, &tg.OptAnswerCallbackQuery{Text: "done"})
+				return err
+			case "failed":
+				return errors.New("failed")
+			default:
+				return nil
+			}
+		}).
+		Start(updates...)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Equal(t, []string{"answered:done", "forgotten:", "failed:oops (alert)", "button:", "button_failed:oops (alert)"}, answers)
+}
@@ -0,0 +1,253 @@
+package tgtesting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/kittenbark/tg"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCommandLocalized(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		Commands     []*tg.BotCommand `json:"commands"`
+		Scope        map[string]any   `json:"scope"`
+		LanguageCode string           `json:"language_code"`
+	}
+	mutex, gets, sets := sync.Mutex{}, 0, []*request{}
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/getMyCommands", Result: func(req *http.Request) (int, *Response) {
+				body := &request{}
+				_ = json.NewDecoder(req.Body).Decode(body)
+				mutex.Lock()
+				defer mutex.Unlock()
+				gets++
+				if body.LanguageCode == "" && body.Scope["type"] == "default" {
+					return StubResultOK(http.StatusOK, []*tg.BotCommand{
+						{Command: "start", Description: "Start"},
+						{Command: "help", Description: "Help"},
+					})(req)
+				}
+				return StubResultOK(http.StatusOK, []*tg.BotCommand{})(req)
+			}},
+			{Url: "/setMyCommands", Result: func(req *http.Request) (int, *Response) {
+				body := &request{}
+				_ = json.NewDecoder(req.Body).Decode(body)
+				mutex.Lock()
+				defer mutex.Unlock()
+				sets = append(sets, body)
+				return StubResultOK(http.StatusOK, true)(req)
+			}},
+		},
+	})
+
+	noop := func(ctx context.Context, upd *tg.Update) error { return nil }
+	bot := tg.New(&tg.Config{Token: ctx.Value(tg.ContextToken).(string), ApiURL: ctx.Value(tg.ContextApiUrl).(string)}).
+		CommandLocalized("/start", tg.CommandDescriptions{"": "Start", "ru": "Начать"}, noop).
+		CommandLocalized("/help", tg.CommandDescriptions{"": "Help"}, noop).
+		CommandLocalized("/ban", tg.CommandDescriptions{"": "Ban", "uk": "Заблокувати"}, noop, &tg.BotCommandScopeAllChatAdministrators{})
+	require.NoError(t, bot.SyncCommands(ctx))
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Equal(t, 4, gets)
+	require.Equal(t, 3, len(sets))
+	for _, set := range sets {
+		switch {
+		case set.Scope["type"] == "default" && set.LanguageCode == "ru":
+			require.Equal(t, []*tg.BotCommand{{Command: "start", Description: "Начать"}, {Command: "help", Description: "Help"}}, set.Commands)
+		case set.Scope["type"] == "all_chat_administrators" && set.LanguageCode == "uk":
+			require.Equal(t, []*tg.BotCommand{{Command: "ban", Description: "Заблокувати"}}, set.Commands)
+		case set.Scope["type"] == "all_chat_administrators" && set.LanguageCode == "":
+			require.Equal(t, []*tg.BotCommand{{Command: "ban", Description: "Ban"}}, set.Commands)
+		default:
+			t.Fatalf("unexpected setMyCommands %v %s", set.Scope, set.LanguageCode)
+		}
+	}
+}
+
+func TestCommandDefinition(t *testing.T) {
+	t.Parallel()
+
+	mutex, replies := sync.Mutex{}, []string{}
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/getUpdates", Result: StubResultOK(http.StatusOK, []*tg.Update{})},
+			{Url: "/getMyCommands", Result: StubResultOK(http.StatusOK, []*tg.BotCommand{})},
+			{Url: "/setMyCommands", Result: StubResultOK(http.StatusOK, true)},
+			{Url: "/sendMessage", Result: func(req *http.Request) (int, *Response) {
+				body := map[string]any{}
+				_ = json.NewDecoder(req.Body).Decode(&body)
+				mutex.Lock()
+				defer mutex.Unlock()
+				replies = append(replies, body["text"].(string))
+				return StubResultOK(http.StatusOK, &tg.Message{MessageId: 1, Chat: &tg.Chat{Id: 1}})(req)
+			}},
+		},
+	})
+
+	type call struct {
+		In     time.Duration
+		Text   string
+		Silent bool
+		Who    *tg.User
+	}
+	calls := []*call{}
+	bot := tg.New(&tg.Config{
+		Token:        ctx.Value(tg.ContextToken).(string),
+		ApiURL:       ctx.Value(tg.ContextApiUrl).(string),
+		SyncHandling: true,
+	})
+	time.AfterFunc(time.Millisecond*200, bot.Stop)
+
+	kitten := &tg.User{Id: 42, FirstName: "Kitten"}
+	mention := tg.NewText().Entity(&tg.MessageEntity{Type: tg.EntityBotCommand}, "/remind").Plain(" 1d2h hi ").Mention("Kitten", kitten)
+	updates := []*tg.Update{}
+	for i, text := range []*tg.Text{
+		command("/remind 1h30m \"buy milk\" --silent"),
+		command("/r 10m"),
+		command("/remind --silent=false 5s 'it\\'s' @kittenbark"),
+		mention,
+		command("/remind soon"),
+		command("/remind"),
+		command("/remind 1m --loud"),
+		command("/help"),
+	} {
+		updates = append(updates, &tg.Update{UpdateId: int64(i), Message: &tg.Message{
+			MessageId: int64(i), Chat: &tg.Chat{Id: 1}, Text: text.String(), Entities: text.Entities(),
+		}})
+	}
+	bot.
+		Commands(&tg.CommandDefinition{
+			Command:     "/remind",
+			Aliases:     []string{"/r"},
+			Description: "Remind me later",
+			Args: []*tg.CommandArg{
+				{Name: "in", Type: tg.ArgDuration, Required: true},
+				{Name: "text", Type: tg.ArgString, Default: "ping"},
+				{Name: "who", Type: tg.ArgUser},
+				{Name: "silent", Type: tg.ArgBool, Flag: true},
+			},
+			Handler: func(ctx context.Context, upd *tg.Update, args *tg.CommandArgs) error {
+				calls = append(calls, &call{args.Duration("in"), args.String("text"), args.Bool("silent"), args.User("who")})
+				return nil
+			},
+		}).
+		CommandHelp()
+	slices.Reverse(updates) // Start handles updates from the last one.
+	bot.Start(updates...)
+
+	require.Equal(t, []*call{
+		{In: 90 * time.Minute, Text: "buy milk", Silent: true},
+		{In: 10 * time.Minute, Text: "ping"},
+		{In: 5 * time.Second, Text: "it's", Who: &tg.User{Username: "kittenbark"}},
+		{In: 26 * time.Hour, Text: "hi", Who: kitten},
+	}, calls)
+	mutex.Lock()
+	defer mutex.Unlock()
+	usage := "\nUsage: /remind <in> [text] [who] [--silent]"
+	require.Equal(t, []string{
+		`bad value "soon" of <in>` + usage,
+		"missing argument <in>" + usage,
+		"unknown flag --loud" + usage,
+		"/remind <in> [text] [who] [--silent] — Remind me later",
+	}, replies)
+}
+
+func command(text string) *tg.Text {
+	name, rest, _ := strings.Cut(text, " ")
+	result := tg.NewText().Entity(&tg.MessageEntity{Type: tg.EntityBotCommand}, name)
+	if rest != "" {
+		result.Plain(" " + rest)
+	}
+	return result
+}
+
+func TestCommonArgsTags(t *testing.T) {
+	t.Parallel()
+
+	type Args struct {
+		In      time.Duration `arg:"in,required"`
+		At      time.Time     `arg:"at"`
+		Who     *tg.User      `arg:"who"`
+		Level   logLevel      `arg:"level" default:"warn"`
+		Skipped string        `arg:"-"`
+		Tags    []string      `arg:"tags"`
+	}
+	type Rest struct {
+		Count int    `default:"1"`
+		Text  string `arg:"text,rest"`
+	}
+
+	kitten := &tg.User{Id: 42}
+	text := command(`/remind 1d 2025-01-02 `).Mention("kitten", kitten).Plain(" error a \"b c\"")
+	parsed := &Args{}
+	err := tg.CommonArgs(func(ctx context.Context, upd *tg.Update, args *Args) error {
+		parsed = args
+		return nil
+	})(context.Background(), &tg.Update{Message: &tg.Message{Text: text.String(), Entities: text.Entities()}})
+	require.NoError(t, err)
+	require.Equal(t, 24*time.Hour, parsed.In)
+	require.Equal(t, "2025-01-02", parsed.At.Format(time.DateOnly))
+	require.Equal(t, kitten, parsed.Who)
+	require.Equal(t, logLevel("ERROR"), parsed.Level)
+	require.Equal(t, []string{"a", "b c"}, parsed.Tags)
+
+	err = tg.CommonArgs(func(ctx context.Context, upd *tg.Update, args *Args) error {
+		require.Equal(t, logLevel("WARN"), args.Level)
+		return nil
+	})(context.Background(), &tg.Update{Message: &tg.Message{Text: "/remind 10m"}})
+	require.NoError(t, err)
+
+	err = tg.CommonArgs(func(ctx context.Context, upd *tg.Update, args *Rest) error {
+		require.Equal(t, Rest{Count: 3, Text: "it's the rest"}, *args)
+		return nil
+	})(context.Background(), &tg.Update{Message: &tg.Message{Text: "/say 3 it's the rest"}})
+	require.NoError(t, err)
+
+	type Ban struct {
+		IP   net.IP
+		Name string
+	}
+	err = tg.CommonArgs(func(ctx context.Context, upd *tg.Update, args *Ban) error {
+		require.Equal(t, "1.2.3.4", args.IP.String())
+		require.Equal(t, "bob", args.Name)
+		return nil
+	})(context.Background(), &tg.Update{Message: &tg.Message{Text: "/ban 1.2.3.4 bob"}})
+	require.NoError(t, err)
+
+	var errArgs *tg.ErrorCommandArgs
+	for text, reason := range map[string]string{
+		"/remind":                   "missing argument <in>",
+		"/remind soon":              `bad value "soon" of <in>`,
+		"/remind 1m 2025-13-45":     `bad value "2025-13-45" of <at>`,
+		"/remind 1m 12:00 @k wrong": `bad value "wrong" of <level>`,
+	} {
+		err = tg.CommonArgs(func(ctx context.Context, upd *tg.Update, args *Args) error { return nil })(
+			context.Background(), &tg.Update{Message: &tg.Message{Text: text}},
+		)
+		require.True(t, errors.As(err, &errArgs), text)
+		require.Equal(t, reason, errArgs.Reason)
+	}
+}
+
+type logLevel string
+
+func (level *logLevel) UnmarshalText(text []byte) error {
+	switch value := strings.ToUpper(string(text)); value {
+	case "DEBUG", "INFO", "WARN", "ERROR":
+		*level = logLevel(value)
+		return nil
+	default:
+		return errors.New("unknown level")
+	}
+}
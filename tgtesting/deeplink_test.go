@@ -0,0 +1,51 @@
+package tgtesting
+
+import (
+	"context"
+	"github.com/kittenbark/tg"
+	"strings"
+	"testing"
+)
+
+func TestDeepLink(t *testing.T) {
+	t.Parallel()
+
+	type Referral struct {
+		From int64  `json:"f"`
+		Note string `json:"n"`
+	}
+	payload, err := tg.EncodePayload("ref_", &Referral{From: 42, Note: "привет?&"})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(payload, "ref_"))
+
+	link, err := tg.DeepLink("@kittenbark_bot", payload)
+	require.NoError(t, err)
+	require.Equal(t, "https://t.me/kittenbark_bot?start="+payload, link)
+	link, err = tg.DeepLink("kittenbark_bot", "", tg.DeepLinkStartGroup)
+	require.NoError(t, err)
+	require.Equal(t, "https://t.me/kittenbark_bot?startgroup", link)
+	link, err = tg.DeepLink("kittenbark_bot", strings.Repeat("a", 100), tg.DeepLinkStartApp)
+	require.NoError(t, err)
+	require.Equal(t, "https://t.me/kittenbark_bot?startapp="+strings.Repeat("a", 100), link)
+	_, err = tg.DeepLink("kittenbark_bot", strings.Repeat("a", 65))
+	require.Error(t, err)
+	_, err = tg.DeepLink("kittenbark_bot", "with space")
+	require.Error(t, err)
+
+	text := command("/start@kittenbark_bot " + payload)
+	upd := &tg.Update{Message: &tg.Message{Text: text.String(), Entities: text.Entities()}}
+	require.Equal(t, payload, tg.StartPayload(upd))
+	require.True(t, tg.OnStartPayload("ref_")(context.Background(), upd))
+	require.False(t, tg.OnStartPayload("promo_")(context.Background(), upd))
+	empty := command("/start")
+	require.False(t, tg.OnStartPayload("")(context.Background(), &tg.Update{Message: &tg.Message{Text: empty.String(), Entities: empty.Entities()}}))
+
+	called := false
+	err = tg.CommonStartPayload("ref_", func(ctx context.Context, upd *tg.Update, ref *Referral) error {
+		called = true
+		require.Equal(t, Referral{From: 42, Note: "привет?&"}, *ref)
+		return nil
+	})(context.Background(), upd)
+	require.NoError(t, err)
+	require.True(t, called)
+}
@@ -0,0 +1,117 @@
+package tgtesting
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/kittenbark/tg"
+	"net/http"
+	"path"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFSM(t *testing.T) {
+	t.Parallel()
+
+	mutex, replies := sync.Mutex{}, []string{}
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/getUpdates", Result: StubResultOK(http.StatusOK, []*tg.Update{})},
+			{Url: "/sendMessage", Result: func(req *http.Request) (int, *Response) {
+				body := map[string]any{}
+				_ = json.NewDecoder(req.Body).Decode(&body)
+				mutex.Lock()
+				defer mutex.Unlock()
+				replies = append(replies, body["text"].(string))
+				return StubResultOK(http.StatusOK, &tg.Message{MessageId: 1, Chat: &tg.Chat{Id: 1}})(req)
+			}},
+		},
+	})
+
+	type Form struct {
+		Name string `json:"name"`
+	}
+	statesPath := path.Join(t.TempDir(), "states.json")
+	storage, err := tg.NewStateStorageJSON(statesPath)
+	require.NoError(t, err)
+	fsm := tg.NewFSM(storage).
+		CancelCommands("/cancel").
+		OnCancel(tg.CommonTextReply("cancelled")).
+		State("name", func(ctx context.Context, upd *tg.Update) error {
+			if err := tg.SetState(ctx, upd, "age", &Form{Name: upd.Message.Text}); err != nil {
+				return err
+			}
+			return tg.CommonTextReply("how old are you?")(ctx, upd)
+		}).
+		State("age", func(ctx context.Context, upd *tg.Update) error {
+			form, err := tg.StateData[Form](ctx, upd)
+			if err != nil {
+				return err
+			}
+			if err = tg.ClearState(ctx, upd); err != nil {
+				return err
+			}
+			return tg.CommonTextReply(form.Name+" is "+upd.Message.Text)(ctx, upd)
+		})
+
+	bot := tg.New(&tg.Config{
+		Token:        ctx.Value(tg.ContextToken).(string),
+		ApiURL:       ctx.Value(tg.ContextApiUrl).(string),
+		SyncHandling: true,
+	})
+	time.AfterFunc(time.Millisecond*200, bot.Stop)
+	updates := []*tg.Update{}
+	for i, text := range []*tg.Text{
+		tg.NewText().Plain("hello"),
+		command("/form"),
+		tg.NewText().Plain("Kitten"),
+		tg.NewText().Plain("3"),
+		command("/form"),
+		command("/cancel"),
+		tg.NewText().Plain("hello"),
+	} {
+		updates = append(updates, &tg.Update{UpdateId: int64(i), Message: &tg.Message{
+			MessageId: int64(i),
+			Chat:      &tg.Chat{Id: 1},
+			From:      &tg.User{Id: 1},
+			Text:      text.String(),
+			Entities:  text.Entities(),
+		}})
+	}
+	slices.Reverse(updates)
+	bot.
+		FSM(fsm).
+		Command("/form", func(ctx context.Context, upd *tg.Update) error {
+			if err := tg.SetState(ctx, upd, "name"); err != nil {
+				return err
+			}
+			return tg.CommonTextReply("what is your name?")(ctx, upd)
+		}).
+		Handle(tg.CommonTextReply("no state")).
+		Start(updates...)
+
+	mutex.Lock()
+	require.Equal(t, []string{
+		"no state", "what is your name?", "how old are you?", "Kitten is 3", "what is your name?", "cancelled", "no state",
+	}, replies)
+	replies = nil
+	mutex.Unlock()
+
+	// States survive restarts with the JSON storage and expire after the timeout.
+	timeoutCtx := context.WithValue(ctx, tg.ContextFSM, fsm)
+	upd := &tg.Update{Message: &tg.Message{Chat: &tg.Chat{Id: 2}, From: &tg.User{Id: 2}}}
+	require.NoError(t, tg.SetState(timeoutCtx, upd, "age", &Form{Name: "Cat"}))
+	reloaded, err := tg.NewStateStorageJSON(statesPath)
+	require.NoError(t, err)
+	reloadedCtx := context.WithValue(ctx, tg.ContextFSM, tg.NewFSM(reloaded))
+	form, err := tg.StateData[Form](reloadedCtx, upd)
+	require.NoError(t, err)
+	require.Equal(t, "Cat", form.Name)
+
+	fsm.Timeout(time.Millisecond)
+	require.NoError(t, tg.SetState(timeoutCtx, upd, "age"))
+	time.Sleep(time.Millisecond * 5)
+	require.True(t, tg.GetState(timeoutCtx, upd) == nil)
+}
@@ -0,0 +1,73 @@
+package tgtesting
+
+import (
+	"encoding/json"
+	"github.com/kittenbark/tg"
+	"net/http"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestI18n(t *testing.T) {
+	t.Parallel()
+
+	i18n := tg.NewI18n("en")
+	require.NoError(t, i18n.LoadFS(fstest.MapFS{
+		"en.json": {Data: []byte(`{
+			"hello": "Hi, {{.Message.From.FirstName}}!",
+			"cats": {"one": "{{.Count}} cat", "other": "{{.Count}} cats"},
+			"errors": {"not_found": "Nothing found"},
+			"only_en": "English only"
+		}`)},
+		"ru.json": {Data: []byte(`{
+			"hello": "Привет, {{.Message.From.FirstName}}!",
+			"cats": {"one": "{{.Count}} кот", "few": "{{.Count}} кота", "many": "{{.Count}} котов", "other": "{{.Count}} кота"},
+			"errors": {"not_found": "Ничего не найдено"}
+		}`)},
+	}))
+
+	for count, expected := range map[int64]string{1: "1 кот", 2: "2 кота", 5: "5 котов", 11: "11 котов", 21: "21 кот", 104: "104 кота"} {
+		require.Equal(t, expected, i18n.TN("ru-RU", "cats", count))
+	}
+	require.Equal(t, "1 cat", i18n.TN("en", "cats", 1))
+	require.Equal(t, "0 cats", i18n.TN("de", "cats", 0))
+	require.Equal(t, "Ничего не найдено", i18n.T("ru", "errors.not_found"))
+	require.Equal(t, "English only", i18n.T("ru", "only_en"))
+	require.Equal(t, "missing", i18n.T("ru", "missing"))
+	require.Error(t, i18n.LoadJSON("en", []byte(`{"broken": "{{.Unclosed"}`)))
+
+	mutex, sent := sync.Mutex{}, []string{}
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/getUpdates", Result: StubResultOK(http.StatusOK, []*tg.Update{})},
+			{Url: "/sendMessage", Result: func(req *http.Request) (int, *Response) {
+				body := map[string]any{}
+				_ = json.NewDecoder(req.Body).Decode(&body)
+				mutex.Lock()
+				defer mutex.Unlock()
+				sent = append(sent, body["text"].(string))
+				return StubResultOK(http.StatusOK, &tg.Message{MessageId: 1, Chat: &tg.Chat{Id: 1}})(req)
+			}},
+		},
+	})
+	bot := tg.New(&tg.Config{
+		Token:        ctx.Value(tg.ContextToken).(string),
+		ApiURL:       ctx.Value(tg.ContextApiUrl).(string),
+		SyncHandling: true,
+	})
+	time.AfterFunc(time.Millisecond*200, bot.Stop)
+	bot.
+		I18n(i18n).
+		Handle(tg.CommonTextReplyT("hello")).
+		Start(
+			&tg.Update{UpdateId: 1, Message: &tg.Message{Chat: &tg.Chat{Id: 1}, From: &tg.User{Id: 1, FirstName: "Котик", LanguageCode: "ru"}}},
+			&tg.Update{UpdateId: 2, Message: &tg.Message{Chat: &tg.Chat{Id: 2}, From: &tg.User{Id: 2, FirstName: "Kitten", LanguageCode: "en-US"}}},
+		)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Equal(t, 2, len(sent))
+	require.True(t, sent[0] == "Hi, Kitten!" && sent[1] == "Привет, Котик!" || sent[0] == "Привет, Котик!" && sent[1] == "Hi, Kitten!", sent...)
+}
@@ -0,0 +1,272 @@
+package tgtesting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/kittenbark/tg"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFileCache(t *testing.T) {
+	t.Parallel()
+
+	uploads, reuses, revoked := &atomic.Int64{}, &atomic.Int64{}, &atomic.Bool{}
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/sendPhoto", Result: func(req *http.Request) (int, *Response) {
+				if err := req.ParseMultipartForm(1 << 20); err != nil {
+					return StubResultError(http.StatusBadRequest, err.Error())(req)
+				}
+				switch {
+				case req.MultipartForm.Value["chat_id"][0] == "2":
+					return StubResultError(http.StatusBadRequest, "Bad Request: chat not found")(req)
+				case len(req.MultipartForm.File["photo"]) == 1:
+					uploads.Add(1)
+				case req.MultipartForm.Value["photo"][0] == "cached_id" && revoked.Load():
+					return StubResultError(http.StatusBadRequest, "Bad Request: wrong file identifier/HTTP URL specified")(req)
+				case req.MultipartForm.Value["photo"][0] == "cached_id":
+					reuses.Add(1)
+				default:
+					return StubResultError(http.StatusBadRequest, "wrong file identifier")(req)
+				}
+				return StubResultOK(http.StatusOK, &tg.Message{
+					MessageId: 1,
+					Chat:      &tg.Chat{Id: 1},
+					Photo:     tg.TelegramPhoto{{FileId: "cached_id", FileUniqueId: "unique"}},
+				})(req)
+			}},
+		},
+	})
+
+	dir := t.TempDir()
+	file := path.Join(dir, "photo.jpg")
+	require.NoError(t, os.WriteFile(file, []byte("not really a photo"), 0644))
+	storage, err := tg.NewFileCacheStorageJSON(path.Join(dir, "cache.json"))
+	require.NoError(t, err)
+	ctx = context.WithValue(ctx, tg.ContextFileCache, tg.NewFileCache(storage))
+
+	for range 3 {
+		_, err := tg.SendPhoto(ctx, 1, tg.FromDisk(file))
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(1), uploads.Load())
+	require.Equal(t, int64(2), reuses.Load())
+
+	reloaded, err := tg.NewFileCacheStorageJSON(path.Join(dir, "cache.json"))
+	require.NoError(t, err)
+	_, err = tg.SendPhoto(context.WithValue(ctx, tg.ContextFileCache, tg.NewFileCache(reloaded)), 1, tg.FromDisk(file))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), uploads.Load())
+	require.Equal(t, int64(3), reuses.Load())
+
+	require.NoError(t, os.WriteFile(file, []byte("a brand new photo, bigger"), 0644))
+	_, err = tg.SendPhoto(ctx, 1, tg.FromDisk(file))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), uploads.Load())
+
+	_, err = tg.SendPhoto(ctx, 2, tg.FromDisk(file))
+	require.Error(t, err)
+	_, err = tg.SendPhoto(ctx, 1, tg.FromDisk(file))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), uploads.Load())
+	require.Equal(t, int64(4), reuses.Load())
+
+	revoked.Store(true)
+	_, err = tg.SendPhoto(ctx, 1, tg.FromDisk(file))
+	require.Error(t, err)
+	_, err = tg.SendPhoto(ctx, 1, tg.FromDisk(file))
+	require.NoError(t, err)
+	require.Equal(t, int64(3), uploads.Load())
+}
+
+func TestDownloadReader(t *testing.T) {
+	t.Parallel()
+
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/getFile", Result: StubResultOK(http.StatusOK, &tg.File{
+				FileId:   "file_id",
+				FilePath: "photos/file_0.jpg",
+				FileSize: 5,
+			})},
+		},
+	})
+	mux := ctx.Value(ContextTestingMuxServer).(*http.ServeMux)
+	mux.HandleFunc("/file/bot"+ctx.Value(tg.ContextToken).(string)+"/photos/file_0.jpg", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	reader, file, err := tg.GenericDownloadReader(ctx, "file_id")
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	require.Equal(t, "hello", string(data))
+	require.Equal(t, "photos/file_0.jpg", file.FilePath)
+
+	photo := tg.TelegramPhoto{{FileId: "file_id"}}
+	reader, _, err = photo.DownloadReader(ctx)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+}
+
+func TestDownloadVerification(t *testing.T) {
+	t.Parallel()
+
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/getFile", Result: func(req *http.Request) (int, *Response) {
+				body := map[string]string{}
+				_ = json.NewDecoder(req.Body).Decode(&body)
+				return StubResultOK(http.StatusOK, &tg.File{
+					FileId:       body["file_id"],
+					FileUniqueId: "unique_" + body["file_id"],
+					FilePath:     "documents/" + body["file_id"],
+					FileSize:     5,
+				})(req)
+			}},
+		},
+	})
+	mux := ctx.Value(ContextTestingMuxServer).(*http.ServeMux)
+	token := ctx.Value(tg.ContextToken).(string)
+	mux.HandleFunc("/file/bot"+token+"/documents/complete", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	})
+	mux.HandleFunc("/file/bot"+token+"/documents/truncated", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("hel"))
+	})
+
+	dir := t.TempDir()
+	err := tg.GenericDownload(ctx, path.Join(dir, "truncated"), "truncated")
+	var errTruncated *tg.ErrorDownloadTruncated
+	require.True(t, errors.As(err, &errTruncated))
+	require.Equal(t, int64(3), errTruncated.Actual)
+	_, err = os.Stat(path.Join(dir, "truncated"))
+	require.True(t, os.IsNotExist(err), "partial file must not be left")
+
+	err = tg.GenericDownload(tg.WithDownloadMaxSize(4)(ctx), path.Join(dir, "too_large"), "complete")
+	var errTooLarge *tg.ErrorDownloadTooLarge
+	require.True(t, errors.As(err, &errTooLarge))
+
+	require.NoError(t, os.WriteFile(path.Join(dir, "complete"), []byte("old"), 0644))
+	require.NoError(t, tg.GenericDownload(ctx, path.Join(dir, "complete"), "complete"))
+	data, err := os.ReadFile(path.Join(dir, "complete"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(entries), "no temporary files must be left")
+}
+
+func TestDownloadBatch(t *testing.T) {
+	t.Parallel()
+
+	getFileCalls := &atomic.Int64{}
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/getFile", Result: func(req *http.Request) (int, *Response) {
+				getFileCalls.Add(1)
+				body := map[string]string{}
+				_ = json.NewDecoder(req.Body).Decode(&body)
+				if body["file_id"] == "missing" {
+					return StubResultError(http.StatusBadRequest, "invalid file_id")(req)
+				}
+				return StubResultOK(http.StatusOK, &tg.File{FileId: body["file_id"], FilePath: "photos/" + body["file_id"]})(req)
+			}},
+		},
+	})
+	mux := ctx.Value(ContextTestingMuxServer).(*http.ServeMux)
+	running, maxRunning := &atomic.Int64{}, &atomic.Int64{}
+	mux.HandleFunc("/file/bot"+ctx.Value(tg.ContextToken).(string)+"/photos/", func(w http.ResponseWriter, req *http.Request) {
+		current := running.Add(1)
+		defer running.Add(-1)
+		for previous := maxRunning.Load(); current > previous && !maxRunning.CompareAndSwap(previous, current); {
+			previous = maxRunning.Load()
+		}
+		time.Sleep(time.Millisecond * 20)
+		if path.Base(req.URL.Path) == "broken" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(path.Base(req.URL.Path)))
+	})
+
+	dir := t.TempDir()
+	items := []*tg.DownloadItem{
+		{FileId: "a", Path: path.Join(dir, "a")},
+		{FileId: "b"},
+		{FileId: "a", Path: path.Join(dir, "a_copy")},
+		{FileId: "c"},
+		{FileId: "d"},
+		{FileId: "missing"},
+		{FileId: "broken"},
+	}
+	results := tg.DownloadBatch(ctx, items, &tg.ConfigDownloadBatch{Parallelism: 2, DirAndPattern: []string{dir}})
+	require.Equal(t, len(items), len(results))
+	require.Equal(t, int64(6), getFileCalls.Load())
+	require.LessOrEqualInt(t, 2, maxRunning.Load())
+	for i, result := range results[:5] {
+		require.NoError(t, result.Error)
+		data, err := os.ReadFile(result.Path)
+		require.NoError(t, err)
+		require.Equal(t, items[i].FileId, string(data))
+	}
+	require.Error(t, results[5].Error)
+	require.Error(t, results[6].Error)
+	require.Equal(t, "", results[6].Path)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Equal(t, 5, len(entries)) // a, a_copy and temporary b, c, d; the failed temporary file is removed.
+}
+
+func TestLocalServer(t *testing.T) {
+	t.Parallel()
+
+	serverDir, localDir := "/var/lib/telegram-bot-api", t.TempDir()
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/getFile", Result: StubResultOK(http.StatusOK, &tg.File{
+				FileId:   "file_id",
+				FilePath: serverDir + "/documents/file_0.txt",
+				FileSize: 5,
+			})},
+			{Url: "/sendDocument", Result: func(req *http.Request) (int, *Response) {
+				if err := req.ParseMultipartForm(1 << 20); err != nil {
+					return StubResultError(http.StatusBadRequest, err.Error())(req)
+				}
+				if len(req.MultipartForm.File["document"]) != 0 {
+					return StubResultError(http.StatusBadRequest, "expected file:// uri, got upload")(req)
+				}
+				document := req.MultipartForm.Value["document"][0]
+				if document != "file://"+serverDir+"/uploads/upload.txt" {
+					return StubResultError(http.StatusBadRequest, "unexpected document "+document)(req)
+				}
+				return StubResultOK(http.StatusOK, &tg.Message{MessageId: 1, Chat: &tg.Chat{Id: 1}})(req)
+			}},
+		},
+	})
+	ctx = tg.WithLocalServer(map[string]string{serverDir: localDir})(ctx)
+
+	require.NoError(t, os.MkdirAll(path.Join(localDir, "documents"), 0755))
+	require.NoError(t, os.WriteFile(path.Join(localDir, "documents", "file_0.txt"), []byte("hello"), 0644))
+	output := path.Join(t.TempDir(), "output.txt")
+	require.NoError(t, tg.GenericDownload(ctx, output, "file_id"))
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+	_, err = os.Stat(path.Join(localDir, "documents", "file_0.txt"))
+	require.True(t, os.IsNotExist(err), "local move must consume the server file")
+
+	require.NoError(t, os.MkdirAll(path.Join(localDir, "uploads"), 0755))
+	require.NoError(t, os.WriteFile(path.Join(localDir, "uploads", "upload.txt"), []byte("hello"), 0644))
+	_, err = tg.SendDocument(ctx, 1, tg.FromDisk(path.Join(localDir, "uploads", "upload.txt")))
+	require.NoError(t, err)
+}
@@ -0,0 +1,137 @@
+package tgtesting
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/kittenbark/tg"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMenu(t *testing.T) {
+	t.Parallel()
+
+	type screen struct {
+		Text        string                   `json:"text"`
+		ReplyMarkup *tg.InlineKeyboardMarkup `json:"reply_markup"`
+	}
+	mutex, screens := sync.Mutex{}, []*screen{}
+	script := []string{"Notifications", "Language", "English", "« Back", "Language", "⌂ Home", "Account 1"}
+	record := func(req *http.Request) (int, *Response) {
+		body := &screen{}
+		_ = json.NewDecoder(req.Body).Decode(body)
+		mutex.Lock()
+		defer mutex.Unlock()
+		screens = append(screens, body)
+		return StubResultOK(http.StatusOK, &tg.Message{MessageId: 10, Chat: &tg.Chat{Id: 1}})(req)
+	}
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/getUpdates", Result: func(req *http.Request) (int, *Response) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				if len(script) == 0 || len(screens) == 0 {
+					return StubResultOK(http.StatusOK, []*tg.Update{})(req)
+				}
+				text := script[0]
+				script = script[1:]
+				for _, row := range screens[len(screens)-1].ReplyMarkup.InlineKeyboard {
+					for _, button := range row {
+						if button.Text == text {
+							return StubResultOK(http.StatusOK, []*tg.Update{{CallbackQuery: &tg.CallbackQuery{
+								Id:      text,
+								From:    &tg.User{Id: 1},
+								Message: &tg.Message{MessageId: 10, Chat: &tg.Chat{Id: 1}},
+								Data:    button.CallbackData,
+							}}})(req)
+						}
+					}
+				}
+				t.Errorf("no button %s", text)
+				return StubResultOK(http.StatusOK, []*tg.Update{})(req)
+			}},
+			{Url: "/sendMessage", Result: record},
+			{Url: "/editMessageText", Result: record},
+		},
+	})
+
+	languageCode := ""
+	language := &tg.Menu{
+		Id:   "language",
+		Text: "Pick a language",
+		Dynamic: func(ctx context.Context, upd *tg.Update) ([]*tg.MenuItem, error) {
+			items := []*tg.MenuItem{}
+			for _, code := range []string{"English", "Русский"} {
+				text := code
+				if code == languageCode {
+					text = "✅ " + code
+				}
+				items = append(items, &tg.MenuItem{Text: text, Handler: func(ctx context.Context, upd *tg.Update) error {
+					languageCode = code
+					return nil
+				}})
+			}
+			return items, nil
+		},
+	}
+	notifications := &tg.Menu{Id: "notifications", Text: "Notifications", Items: []*tg.MenuItem{
+		{Text: "Language", Menu: language},
+	}}
+	settings := &tg.Menu{
+		Id:   "settings",
+		Text: "Settings",
+		Items: []*tg.MenuItem{
+			{Text: "Language", Menu: language},
+			{Text: "Notifications", Menu: notifications},
+		},
+		Dynamic: func(ctx context.Context, upd *tg.Update) ([]*tg.MenuItem, error) {
+			return []*tg.MenuItem{{Text: "Account 1", Menu: &tg.Menu{Id: "account:1", Text: "Account 1 settings"}}}, nil
+		},
+		Columns: 2,
+	}
+
+	bot := tg.New(&tg.Config{
+		Token:        ctx.Value(tg.ContextToken).(string),
+		ApiURL:       ctx.Value(tg.ContextApiUrl).(string),
+		SyncHandling: true,
+	})
+	time.AfterFunc(time.Second, bot.Stop)
+	bot.
+		Menu(settings).
+		Command("/settings", func(ctx context.Context, upd *tg.Update) error {
+			_, err := settings.Open(ctx, upd)
+			return err
+		}).
+		Start(&tg.Update{Message: &tg.Message{
+			Chat:     &tg.Chat{Id: 1},
+			From:     &tg.User{Id: 1},
+			Text:     "/settings",
+			Entities: command("/settings").Entities(),
+		}})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	texts := []string{}
+	for _, screen := range screens {
+		texts = append(texts, screen.Text)
+	}
+	require.Equal(t, []string{
+		"Settings",
+		"Notifications",
+		"Pick a language",
+		"Pick a language",
+		"Notifications", // Back returns to the menu the user came from, not to the parent in the tree.
+		"Pick a language",
+		"Settings",
+		"Account 1 settings",
+	}, texts)
+	require.Equal(t, [][]string{{"Language", "Notifications"}, {"Account 1"}}, markupTexts(screens[0].ReplyMarkup))
+	require.Equal(t, [][]string{{"Language"}, {"« Back"}}, markupTexts(screens[1].ReplyMarkup))
+	require.Equal(t, [][]string{{"English"}, {"Русский"}, {"« Back", "⌂ Home"}}, markupTexts(screens[2].ReplyMarkup))
+	require.Equal(t, [][]string{{"✅ English"}, {"Русский"}, {"« Back", "⌂ Home"}}, markupTexts(screens[3].ReplyMarkup))
+	require.Equal(t, [][]string{{"« Back"}}, markupTexts(screens[7].ReplyMarkup))
+	require.True(t, slices.Equal([]string{}, script))
+}
@@ -0,0 +1,113 @@
+package tgtesting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/kittenbark/tg"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sessionStorageCounting struct {
+	tg.SessionStorage
+	stores int
+}
+
+func (storage *sessionStorageCounting) Store(key string, data []byte, version int64) error {
+	storage.stores++
+	return storage.SessionStorage.Store(key, data, version)
+}
+
+func TestSession(t *testing.T) {
+	t.Parallel()
+
+	mutex, replies := sync.Mutex{}, []string{}
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/getUpdates", Result: StubResultOK(http.StatusOK, []*tg.Update{})},
+			{Url: "/sendMessage", Result: func(req *http.Request) (int, *Response) {
+				body := map[string]any{}
+				_ = json.NewDecoder(req.Body).Decode(&body)
+				mutex.Lock()
+				defer mutex.Unlock()
+				replies = append(replies, body["text"].(string))
+				return StubResultOK(http.StatusOK, &tg.Message{MessageId: 1, Chat: &tg.Chat{Id: 1}})(req)
+			}},
+		},
+	})
+
+	type Counter struct {
+		Count int `json:"count"`
+	}
+	sessionsPath := path.Join(t.TempDir(), "sessions.json")
+	jsonStorage, err := tg.NewSessionStorageJSON(sessionsPath)
+	require.NoError(t, err)
+	storage := &sessionStorageCounting{SessionStorage: jsonStorage}
+
+	bot := tg.New(&tg.Config{
+		Token:        ctx.Value(tg.ContextToken).(string),
+		ApiURL:       ctx.Value(tg.ContextApiUrl).(string),
+		SyncHandling: true,
+	})
+	time.AfterFunc(time.Millisecond*200, bot.Stop)
+	updates := []*tg.Update{}
+	for i, item := range []struct {
+		user int64
+		text string
+	}{{1, "/count"}, {1, "/count"}, {2, "/count"}, {1, "/fail"}, {1, "/show"}, {1, "/count"}} {
+		text := command(item.text)
+		updates = append(updates, &tg.Update{UpdateId: int64(i), Message: &tg.Message{
+			MessageId: int64(i),
+			Chat:      &tg.Chat{Id: item.user},
+			From:      &tg.User{Id: item.user},
+			Text:      text.String(),
+			Entities:  text.Entities(),
+		}})
+	}
+	slices.Reverse(updates)
+	bot.
+		Use(tg.SessionMiddleware[Counter](&tg.OptSession{Storage: storage})).
+		Command("/count", func(ctx context.Context, upd *tg.Update) error {
+			counter := tg.Session[Counter](ctx)
+			counter.Count++
+			return tg.CommonTextReply(strconv.Itoa(counter.Count))(ctx, upd)
+		}).
+		Command("/fail", func(ctx context.Context, upd *tg.Update) error {
+			tg.Session[Counter](ctx).Count += 100
+			return errors.New("failed halfway") // the change is not saved.
+		}).
+		Command("/show", func(ctx context.Context, upd *tg.Update) error {
+			return tg.CommonTextReply("show "+strconv.Itoa(tg.Session[Counter](ctx).Count))(ctx, upd)
+		}).
+		Start(updates...)
+
+	mutex.Lock()
+	require.Equal(t, []string{"1", "2", "1", "show 2", "3"}, replies)
+	mutex.Unlock()
+	require.Equal(t, 4, storage.stores)
+	require.True(t, tg.Session[Counter](ctx) == nil)
+
+	reloaded, err := tg.NewSessionStorageJSON(sessionsPath)
+	require.NoError(t, err)
+	data, version, err := reloaded.Load("user:1")
+	require.NoError(t, err)
+	require.Equal(t, `{"count":3}`, string(data))
+	require.Equal(t, int64(3), version)
+
+	// Optimistic concurrency: the second writer of the same version loses.
+	memory := tg.NewSessionStorageMemory()
+	require.NoError(t, memory.Store("user:1", []byte(`{"count":1}`), 0))
+	_, version, err = memory.Load("user:1")
+	require.NoError(t, err)
+	require.NoError(t, memory.Store("user:1", []byte(`{"count":2}`), version))
+	err = memory.Store("user:1", []byte(`{"count":2}`), version)
+	var conflict *tg.ErrorSessionConflict
+	require.True(t, errors.As(err, &conflict))
+	require.Equal(t, int64(2), conflict.Current)
+}
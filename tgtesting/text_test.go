@@ -0,0 +1,267 @@
+package tgtesting
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/kittenbark/tg"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	t.Parallel()
+
+	user := &tg.User{Id: 42, FirstName: "kitten"}
+	text := tg.NewText().
+		Bold("привет").Plain(" 🐱 ").Italic("mom").Line().
+		Wrap(&tg.MessageEntity{Type: tg.EntityBold}, tg.NewText().Plain("a ").Italic("b")).
+		Link("link", "https://kittenbark.com").
+		Mention("kitten", user).
+		CustomEmoji("👍", "5368324170671202286").
+		Pre("print()", "python").
+		Code("").
+		Spoiler("s").Strikethrough("s").Underline("u").Blockquote("q").ExpandableBlockquote("e")
+
+	require.Equal(t, "привет 🐱 mom\na blinkkitten👍print()ssuqe", text.String())
+	require.Equal(t, []*tg.MessageEntity{
+		{Type: tg.EntityBold, Offset: 0, Length: 6},
+		{Type: tg.EntityItalic, Offset: 10, Length: 3},
+		{Type: tg.EntityBold, Offset: 14, Length: 3},
+		{Type: tg.EntityItalic, Offset: 16, Length: 1},
+		{Type: tg.EntityTextLink, Offset: 17, Length: 4, Url: "https://kittenbark.com"},
+		{Type: tg.EntityTextMention, Offset: 21, Length: 6, User: user},
+		{Type: tg.EntityCustomEmoji, Offset: 27, Length: 2, CustomEmojiId: "5368324170671202286"},
+		{Type: tg.EntityPre, Offset: 29, Length: 7, Language: "python"},
+		{Type: tg.EntitySpoiler, Offset: 36, Length: 1},
+		{Type: tg.EntityStrikethrough, Offset: 37, Length: 1},
+		{Type: tg.EntityUnderline, Offset: 38, Length: 1},
+		{Type: tg.EntityBlockquote, Offset: 39, Length: 1},
+		{Type: tg.EntityExpandableBlockquote, Offset: 40, Length: 1},
+	}, text.Entities())
+	require.Equal(t, int64(41), text.Len())
+
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{
+				Url: "/sendMessage",
+				Validator: func(req *http.Request) bool {
+					var body struct {
+						Text     string              `json:"text"`
+						Entities []*tg.MessageEntity `json:"entities"`
+					}
+					return json.NewDecoder(req.Body).Decode(&body) == nil &&
+						body.Text == text.String() && len(body.Entities) == len(text.Entities())
+				},
+				Result: StubResultOK(http.StatusOK, &tg.Message{MessageId: 1, Chat: &tg.Chat{Id: 1}}),
+			},
+		},
+	})
+	_, err := tg.SendText(ctx, 1, text)
+	require.NoError(t, err)
+}
+
+func TestTextMarkup(t *testing.T) {
+	t.Parallel()
+
+	user := &tg.User{Id: 42}
+	text := tg.NewText().
+		Bold("жирный 🐱").Plain(" ").Italic("a_b").Plain(" ").Underline("<u>").Plain(" ").
+		Strikethrough("s").Plain(" ").Spoiler("||").Plain(" ").Code("a`b").Plain(" ").
+		Link("link", "https://kittenbark.com/(x)").Plain(" ").Mention("kitten", user).Plain(" ").
+		CustomEmoji("👍", "5368324170671202286").
+		Wrap(&tg.MessageEntity{Type: tg.EntityItalic}, tg.NewText().Underline("iu")).Line().
+		Pre("if a < b {\n}", "go").Line().
+		Blockquote("quote\nline").Line().
+		ExpandableBlockquote("expandable\nline")
+	// Entities detected by Telegram are not a part of markup.
+	auto := tg.NewText()
+	for _, entity := range [][]string{
+		{tg.EntityMention, "@kittenbark"}, {tg.EntityHashtag, "#tag"}, {tg.EntityCashtag, "$USD"}, {tg.EntityBotCommand, "/start"},
+		{tg.EntityUrl, "https://kittenbark.com"}, {tg.EntityEmail, "a@b.c"}, {tg.EntityPhoneNumber, "+1-212-555-0123"},
+	} {
+		auto.Entity(&tg.MessageEntity{Type: entity[0]}, entity[1]).Plain(" ")
+	}
+	text = tg.TextFromEntities(auto.String()+text.String(), append(auto.Entities(), shift(text.Entities(), auto.Len())...))
+
+	html := text.HTML()
+	require.Equal(t, "@kittenbark #tag $USD /start https://kittenbark.com a@b.c +1-212-555-0123 "+
+		`<b>жирный 🐱</b> <i>a_b</i> <u>&lt;u&gt;</u> <s>s</s> <tg-spoiler>||</tg-spoiler> <code>a`+"`"+`b</code> `+
+		`<a href="https://kittenbark.com/(x)">link</a> <a href="tg://user?id=42">kitten</a> `+
+		`<tg-emoji emoji-id="5368324170671202286">👍</tg-emoji><i><u>iu</u></i>`+"\n"+
+		`<pre><code class="language-go">if a &lt; b {`+"\n"+`}</code></pre>`+"\n"+
+		"<blockquote>quote\nline</blockquote>\n<blockquote expandable>expandable\nline</blockquote>", html)
+	parsed, err := tg.ParseHTML(html)
+	require.NoError(t, err)
+	require.Equal(t, text.String(), parsed.String())
+	require.Equal(t, text.Entities()[7:], parsed.Entities())
+
+	md := text.MarkdownV2()
+	require.Equal(t, "@kittenbark \\#tag $USD /start https://kittenbark\\.com a@b\\.c \\+1\\-212\\-555\\-0123 "+
+		"*жирный 🐱* _a\\_b_ __<u\\>__ ~s~ ||\\|\\||| `a\\`b` "+
+		"[link](https://kittenbark.com/(x\\)) [kitten](tg://user?id=42) "+
+		"![👍](tg://emoji?id=5368324170671202286)_\r__iu__\r_\n"+
+		"```go\nif a < b {\n}\n```\n"+
+		">quote\n>line\n**>expandable\n>line||", md)
+	parsed, err = tg.ParseMarkdownV2(md)
+	require.NoError(t, err)
+	require.Equal(t, text.String(), parsed.String())
+	require.Equal(t, text.Entities()[7:], parsed.Entities())
+
+	overlapping := tg.TextFromEntities("abc", []*tg.MessageEntity{
+		{Type: tg.EntityBold, Offset: 0, Length: 2},
+		{Type: tg.EntityItalic, Offset: 1, Length: 2},
+	})
+	require.Equal(t, "<b>a<i>b</i></b><i>c</i>", overlapping.HTML())
+
+	for _, markup := range []string{"<b>unclosed", "<b><i>wrong</b></i>", "<marquee>no</marquee>"} {
+		_, err = tg.ParseHTML(markup)
+		require.Error(t, err, markup)
+	}
+	for _, markup := range []string{"*unclosed", "`code", "[link](https://kittenbark.com"} {
+		_, err = tg.ParseMarkdownV2(markup)
+		require.Error(t, err, markup)
+	}
+}
+
+func TestTextSplit(t *testing.T) {
+	t.Parallel()
+
+	text := tg.NewText().
+		Bold("First paragraph.").Plain("\n\n").
+		Italic("Second one is longer. It has two sentences.").Plain(" ").
+		CustomEmoji("👍", "1").Plain(strings.Repeat("🐱", 30))
+	parts, err := text.Split(32)
+	require.NoError(t, err)
+	strs := []string{}
+	for _, part := range parts {
+		require.LessOrEqualInt(t, 32, part.Len())
+		strs = append(strs, part.String())
+	}
+	require.Equal(t, []string{
+		"First paragraph.",
+		"Second one is longer.",
+		"It has two sentences.",
+		"👍" + strings.Repeat("🐱", 15),
+		strings.Repeat("🐱", 15),
+	}, strs)
+	require.Equal(t, []*tg.MessageEntity{{Type: tg.EntityBold, Offset: 0, Length: 16}}, parts[0].Entities())
+	require.Equal(t, []*tg.MessageEntity{{Type: tg.EntityItalic, Offset: 0, Length: 21}}, parts[1].Entities())
+	require.Equal(t, []*tg.MessageEntity{{Type: tg.EntityItalic, Offset: 0, Length: 21}}, parts[2].Entities())
+	require.Equal(t, []*tg.MessageEntity{{Type: tg.EntityCustomEmoji, Offset: 0, Length: 2, CustomEmojiId: "1"}}, parts[3].Entities())
+	parts, err = text.Split(tg.MessageTextLimit)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(parts))
+}
+
+func TestTextSplitTinyLimit(t *testing.T) {
+	t.Parallel()
+
+	parts, err := tg.NewText().Plain("👍👍").Split(1)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(parts))
+	require.Equal(t, []string{"👍", "👍"}, []string{parts[0].String(), parts[1].String()})
+
+	parts, err = tg.NewText().Plain("a  b").Split(1)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(parts))
+	require.Equal(t, []string{"a", " ", "b"}, []string{parts[0].String(), parts[1].String(), parts[2].String()})
+
+	for _, limit := range []int64{0, -1} {
+		_, err = tg.NewText().Plain("text").Split(limit)
+		require.Error(t, err)
+	}
+}
+
+func TestSendLongMessage(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		Text            string              `json:"text"`
+		ParseMode       string              `json:"parse_mode"`
+		Entities        []*tg.MessageEntity `json:"entities"`
+		ReplyParameters *tg.ReplyParameters `json:"reply_parameters"`
+		ReplyMarkup     json.RawMessage     `json:"reply_markup"`
+	}
+	mutex, requests := sync.Mutex{}, []*request{}
+	ctx := NewTestingContext(t, &Config{
+		Stubs: []Stub{
+			{Url: "/sendMessage", Result: func(req *http.Request) (int, *Response) {
+				body := &request{}
+				if err := json.NewDecoder(req.Body).Decode(body); err != nil {
+					return StubResultError(http.StatusBadRequest, err.Error())(req)
+				}
+				mutex.Lock()
+				defer mutex.Unlock()
+				requests = append(requests, body)
+				return StubResultOK(http.StatusOK, &tg.Message{MessageId: int64(len(requests) + 1), Chat: &tg.Chat{Id: 1}})(req)
+			}},
+		},
+	})
+	keyboard := &tg.InlineKeyboardMarkup{InlineKeyboard: [][]*tg.InlineKeyboardButton{{{Text: "ok", CallbackData: "ok"}}}}
+
+	paragraph := strings.Repeat("word ", 500) + "end."
+	msgs, err := tg.SendLongMessage(ctx, 1, "<b>"+paragraph+"\n\n"+paragraph+"</b>", &tg.OptSendMessage{
+		ParseMode:       tg.ParseModeHTML,
+		ReplyParameters: &tg.ReplyParameters{MessageId: 1},
+		ReplyMarkup:     keyboard,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(msgs))
+	require.Equal(t, 2, len(requests))
+	for i, req := range requests {
+		require.Equal(t, paragraph, req.Text)
+		require.Equal(t, "", req.ParseMode)
+		require.Equal(t, []*tg.MessageEntity{{Type: tg.EntityBold, Offset: 0, Length: int64(len(paragraph))}}, req.Entities)
+		require.Equal(t, i == 0, req.ReplyParameters != nil)
+		require.Equal(t, i == 1, req.ReplyMarkup != nil)
+	}
+
+	requests = nil
+	caption := tg.NewText().Plain(strings.Repeat("caption ", 200))
+	msgs, err = tg.SendLongCaption(ctx, 1, caption, func(caption *tg.Text, markup tg.VariantInlineKeyboardMarkupReplyKeyboardMarkupReplyKeyboardRemoveForceReply) (*tg.Message, error) {
+		require.LessOrEqualInt(t, tg.MessageCaptionLimit, caption.Len())
+		require.True(t, markup == nil)
+		return &tg.Message{MessageId: 100, Chat: &tg.Chat{Id: 1}}, nil
+	}, &tg.OptSendMessage{ReplyMarkup: keyboard})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(msgs))
+	require.Equal(t, 1, len(requests))
+	require.Equal(t, int64(100), requests[0].ReplyParameters.MessageId)
+	require.True(t, requests[0].ReplyMarkup != nil)
+}
+
+func shift(entities []*tg.MessageEntity, offset int64) []*tg.MessageEntity {
+	for _, entity := range entities {
+		entity.Offset += offset
+	}
+	return entities
+}
+
+func TestMessageEntities(t *testing.T) {
+	t.Parallel()
+
+	text := tg.NewText().
+		Plain("привет 🐱 ").Entity(&tg.MessageEntity{Type: tg.EntityBotCommand}, "/start@kitten_bot").
+		Plain(" 👍 ").Entity(&tg.MessageEntity{Type: tg.EntityMention}, "@kittenbark").
+		Plain(" ").Entity(&tg.MessageEntity{Type: tg.EntityHashtag}, "#котики").
+		Plain(" ").Entity(&tg.MessageEntity{Type: tg.EntityUrl}, "https://kittenbark.com").
+		Plain(" ").Link("ссылка", "https://t.me/kittenbark")
+	msg := &tg.Message{Text: text.String(), Entities: text.Entities(), Chat: &tg.Chat{Id: 1}}
+
+	commands := msg.EntitiesOfType(tg.EntityBotCommand)
+	require.Equal(t, 1, len(commands))
+	require.Equal(t, "/start@kitten_bot", msg.EntityText(commands[0]))
+	require.Equal(t, []string{"@kittenbark"}, msg.Mentions())
+	require.Equal(t, []string{"#котики"}, msg.Hashtags())
+	require.Equal(t, []string{"https://kittenbark.com", "https://t.me/kittenbark"}, msg.Urls())
+	require.Equal(t, "", msg.EntityText(&tg.MessageEntity{Offset: 1000, Length: 1}))
+
+	captioned := &tg.Message{Caption: text.String(), CaptionEntities: text.Entities()}
+	require.Equal(t, []string{"#котики"}, captioned.Hashtags())
+
+	upd := &tg.Update{Message: msg}
+	require.True(t, tg.OnCommand("start")(context.Background(), upd))
+	require.False(t, tg.OnCommand("stop")(context.Background(), upd))
+}